@@ -1,8 +1,10 @@
 package env
 
 import (
+	"log"
 	"os"
 	"strconv"
+	"time"
 )
 
 // Environment variables, used to override defaults
@@ -16,13 +18,38 @@ const (
 
 	// Toggle the teardown operations during tests (has default)
 	EnvTeardown = "TEARDOWN"
+
+	// Toggle the slow tests that reinstall the snap with different
+	// configurations (has default)
+	EnvFullConfigTest = "FULL_CONFIG_TEST"
+
+	// ID of a snap store proxy to install snaps from, used in environments
+	// without access to the global store. The proxy's store assertion must
+	// already be acknowledged.
+	EnvSnapStoreProxy = "SNAP_STORE_PROXY"
+
+	// Maximum duration of the whole test suite, e.g. 45m, after which the
+	// run is aborted (no limit by default)
+	EnvMaxSuiteDuration = "MAX_SUITE_DURATION"
+
+	// Toggle capturing perf profiles of the device apps (has default)
+	EnvProfile = "PROFILE"
+
+	// Mark the run after a reboot of a multi-phase reboot test, which resumes
+	// from the checkpoint of the run before the reboot (has default)
+	EnvRebootResume = "REBOOT_RESUME"
 )
 
 var (
 	// Defaults
-	snapChannel = "latest/edge"
-	snapPath    = ""
-	teardown    = true
+	snapChannel      = "latest/edge"
+	snapPath         = ""
+	teardown         = true
+	fullConfigTest   = false
+	snapStoreProxy   = ""
+	maxSuiteDuration time.Duration
+	profile          = false
+	rebootResume     = false
 )
 
 // SnapChannel returns the set snap channel
@@ -35,11 +62,46 @@ func SnapPath() string {
 	return snapPath
 }
 
+// SnapInstallSource returns where to install the snap from: the local snap
+// path if set, otherwise the store channel.
+// The local path takes precedence, so the channel is empty when both are set.
+func SnapInstallSource() (path, channel string) {
+	if snapPath != "" {
+		return snapPath, ""
+	}
+	return "", snapChannel
+}
+
 // SkipTeardownRemoval return
 func Teardown() (skip bool) {
 	return teardown
 }
 
+// FullConfigTest returns whether the full config tests should run
+func FullConfigTest() bool {
+	return fullConfigTest
+}
+
+// SnapStoreProxy returns the set snap store proxy ID
+func SnapStoreProxy() string {
+	return snapStoreProxy
+}
+
+// MaxSuiteDuration returns the set maximum duration of the test suite, or 0
+func MaxSuiteDuration() time.Duration {
+	return maxSuiteDuration
+}
+
+// Profile returns whether perf profiles should be captured
+func Profile() bool {
+	return profile
+}
+
+// RebootResume returns whether this run resumes a test after a reboot
+func RebootResume() bool {
+	return rebootResume
+}
+
 func init() {
 	loadEnvVars()
 }
@@ -53,6 +115,11 @@ func loadEnvVars() {
 
 	if v := os.Getenv(EnvSnapPath); v != "" {
 		snapPath = v
+
+		if os.Getenv(EnvSnapChannel) != "" {
+			log.Printf("Both %s and %s are set: installing from %s and ignoring the channel",
+				EnvSnapPath, EnvSnapChannel, snapPath)
+		}
 	}
 
 	if v := os.Getenv(EnvTeardown); v != "" {
@@ -62,4 +129,40 @@ func loadEnvVars() {
 			panic(err)
 		}
 	}
+
+	if v := os.Getenv(EnvSnapStoreProxy); v != "" {
+		snapStoreProxy = v
+	}
+
+	if v := os.Getenv(EnvFullConfigTest); v != "" {
+		var err error
+		fullConfigTest, err = strconv.ParseBool(v)
+		if err != nil {
+			panic(err)
+		}
+	}
+
+	if v := os.Getenv(EnvProfile); v != "" {
+		var err error
+		profile, err = strconv.ParseBool(v)
+		if err != nil {
+			panic(err)
+		}
+	}
+
+	if v := os.Getenv(EnvRebootResume); v != "" {
+		var err error
+		rebootResume, err = strconv.ParseBool(v)
+		if err != nil {
+			panic(err)
+		}
+	}
+
+	if v := os.Getenv(EnvMaxSuiteDuration); v != "" {
+		var err error
+		maxSuiteDuration, err = time.ParseDuration(v)
+		if err != nil {
+			panic(err)
+		}
+	}
 }
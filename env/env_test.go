@@ -0,0 +1,48 @@
+package env
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSnapInstallSource(t *testing.T) {
+	defaultChannel, defaultPath := snapChannel, snapPath
+	reset := func() {
+		snapChannel, snapPath = defaultChannel, defaultPath
+	}
+	t.Cleanup(reset)
+
+	t.Run("default", func(t *testing.T) {
+		t.Cleanup(reset)
+		t.Setenv(EnvSnapChannel, "")
+		t.Setenv(EnvSnapPath, "")
+		loadEnvVars()
+
+		path, channel := SnapInstallSource()
+		assert.Empty(t, path)
+		assert.Equal(t, defaultChannel, channel)
+	})
+
+	t.Run("channel", func(t *testing.T) {
+		t.Cleanup(reset)
+		t.Setenv(EnvSnapChannel, "latest/beta")
+		t.Setenv(EnvSnapPath, "")
+		loadEnvVars()
+
+		path, channel := SnapInstallSource()
+		assert.Empty(t, path)
+		assert.Equal(t, "latest/beta", channel)
+	})
+
+	t.Run("path and channel", func(t *testing.T) {
+		t.Cleanup(reset)
+		t.Setenv(EnvSnapChannel, "latest/beta")
+		t.Setenv(EnvSnapPath, "./chip-tool_amd64.snap")
+		loadEnvVars()
+
+		path, channel := SnapInstallSource()
+		assert.Equal(t, "./chip-tool_amd64.snap", path)
+		assert.Empty(t, channel)
+	})
+}
@@ -0,0 +1,152 @@
+package utils
+
+import (
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strconv"
+	"testing"
+)
+
+// Node ID of chip-tool on its fabric, the subject of its administer privilege
+const chipToolAdminNodeID = 112233
+
+// ACLPrivilege is the privilege granted by an access control entry
+type ACLPrivilege int
+
+const (
+	ACLPrivilegeView       ACLPrivilege = 1
+	ACLPrivilegeProxyView  ACLPrivilege = 2
+	ACLPrivilegeOperate    ACLPrivilege = 3
+	ACLPrivilegeManage     ACLPrivilege = 4
+	ACLPrivilegeAdminister ACLPrivilege = 5
+)
+
+// ACLAuthMode is the authentication mode of an access control entry's subjects
+type ACLAuthMode int
+
+const (
+	ACLAuthModePASE  ACLAuthMode = 1
+	ACLAuthModeCASE  ACLAuthMode = 2
+	ACLAuthModeGroup ACLAuthMode = 3
+)
+
+// ACLTarget restricts an access control entry to a cluster, endpoint and/or
+// device type. Unset fields match any.
+type ACLTarget struct {
+	Cluster    *uint32 `json:"cluster"`
+	Endpoint   *uint16 `json:"endpoint"`
+	DeviceType *uint32 `json:"deviceType"`
+}
+
+// ACLEntry is an entry of the Access Control cluster's ACL attribute.
+// Empty subjects or targets match any.
+type ACLEntry struct {
+	Privilege ACLPrivilege
+	AuthMode  ACLAuthMode
+	// node IDs with CASE, group IDs with Group
+	Subjects []uint64
+	Targets  []ACLTarget
+}
+
+// validate applies the constraints of the Access Control cluster
+func (e ACLEntry) validate() error {
+	if e.Privilege < ACLPrivilegeView || e.Privilege > ACLPrivilegeAdminister {
+		return fmt.Errorf("invalid privilege %d", e.Privilege)
+	}
+	switch e.AuthMode {
+	case ACLAuthModeCASE, ACLAuthModeGroup:
+	case ACLAuthModePASE:
+		return fmt.Errorf("PASE entries can't be written")
+	default:
+		return fmt.Errorf("invalid auth mode %d", e.AuthMode)
+	}
+	if e.AuthMode == ACLAuthModeGroup && e.Privilege == ACLPrivilegeAdminister {
+		return fmt.Errorf("groups can't be granted the administer privilege")
+	}
+	for _, s := range e.Subjects {
+		if s == 0 {
+			return fmt.Errorf("invalid subject 0")
+		}
+		if e.AuthMode == ACLAuthModeGroup && s > 0xffff {
+			return fmt.Errorf("invalid group ID %d", s)
+		}
+	}
+	for _, target := range e.Targets {
+		if target.Cluster == nil && target.Endpoint == nil && target.DeviceType == nil {
+			return fmt.Errorf("targets must set at least one field")
+		}
+		if target.Endpoint != nil && target.DeviceType != nil {
+			return fmt.Errorf("targets can't set both endpoint and device type")
+		}
+	}
+	return nil
+}
+
+// encodeACL validates the entries and serializes them into the JSON argument
+// of chip-tool's `accesscontrol write acl` command
+func encodeACL(entries []ACLEntry) (string, error) {
+	type jsonEntry struct {
+		FabricIndex int          `json:"fabricIndex"`
+		Privilege   ACLPrivilege `json:"privilege"`
+		AuthMode    ACLAuthMode  `json:"authMode"`
+		Subjects    []uint64     `json:"subjects"`
+		Targets     []ACLTarget  `json:"targets"`
+	}
+
+	var hasAdmin bool
+	jsonEntries := make([]jsonEntry, len(entries))
+	for i, e := range entries {
+		if err := e.validate(); err != nil {
+			return "", fmt.Errorf("entry %d: %s", i+1, err)
+		}
+		// empty subjects match any node, including chip-tool
+		if e.Privilege == ACLPrivilegeAdminister && e.AuthMode == ACLAuthModeCASE &&
+			(len(e.Subjects) == 0 || slices.Contains(e.Subjects, chipToolAdminNodeID)) {
+			hasAdmin = true
+		}
+		// empty lists are written as null, matching any
+		jsonEntries[i] = jsonEntry{
+			// chip-tool's fabric
+			FabricIndex: 1,
+			Privilege:   e.Privilege,
+			AuthMode:    e.AuthMode,
+			Subjects:    e.Subjects,
+			Targets:     e.Targets,
+		}
+	}
+	// writing the ACL replaces all entries of the fabric
+	if !hasAdmin {
+		return "", fmt.Errorf("entries must grant CASE administer privilege to node %d, to not lock out chip-tool",
+			chipToolAdminNodeID)
+	}
+
+	b, err := json.Marshal(jsonEntries)
+	return string(b), err
+}
+
+// ChipToolWriteACL replaces the access control entries of chip-tool's fabric
+// on a device and verifies them by reading them back. The entries must keep
+// granting chip-tool the administer privilege.
+func ChipToolWriteACL(t *testing.T, nodeID string, entries []ACLEntry) {
+	acl, err := encodeACL(entries)
+	if err != nil {
+		t.Fatalf("Invalid ACL: %s", err)
+	}
+
+	ChipTool(t, fmt.Sprintf("accesscontrol write acl '%s' %s 0", acl, nodeID))
+
+	stdout, _, _ := ChipTool(t, readAttributeCommand(nodeID, "accesscontrol", "acl", "0"))
+	readEntries := parseListStructs(stdout)
+	if len(readEntries) != len(entries) {
+		t.Fatalf("Read back %d ACL entries instead of %d", len(readEntries), len(entries))
+	}
+	for i, e := range entries {
+		privilege := readEntries[i]["Privilege"]
+		authMode := readEntries[i]["AuthMode"]
+		if privilege != strconv.Itoa(int(e.Privilege)) || authMode != strconv.Itoa(int(e.AuthMode)) {
+			t.Fatalf("ACL entry %d has privilege %s and auth mode %s instead of %d and %d",
+				i+1, privilege, authMode, e.Privilege, e.AuthMode)
+		}
+	}
+}
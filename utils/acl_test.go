@@ -0,0 +1,72 @@
+package utils
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEncodeACL(t *testing.T) {
+	admin := ACLEntry{
+		Privilege: ACLPrivilegeAdminister,
+		AuthMode:  ACLAuthModeCASE,
+		Subjects:  []uint64{112233},
+	}
+
+	t.Run("encode", func(t *testing.T) {
+		cluster := uint32(6)
+		acl, err := encodeACL([]ACLEntry{
+			admin,
+			{
+				Privilege: ACLPrivilegeOperate,
+				AuthMode:  ACLAuthModeGroup,
+				Subjects:  []uint64{257},
+				Targets:   []ACLTarget{{Cluster: &cluster}},
+			},
+		})
+		require.NoError(t, err)
+		assert.JSONEq(t, `[
+			{"fabricIndex": 1, "privilege": 5, "authMode": 2, "subjects": [112233], "targets": null},
+			{"fabricIndex": 1, "privilege": 3, "authMode": 3, "subjects": [257],
+				"targets": [{"cluster": 6, "endpoint": null, "deviceType": null}]}
+		]`, acl)
+	})
+
+	t.Run("invalid entries", func(t *testing.T) {
+		endpoint, deviceType := uint16(1), uint32(256)
+		for name, entry := range map[string]ACLEntry{
+			"invalid privilege":   {Privilege: 6, AuthMode: ACLAuthModeCASE},
+			"invalid auth mode":   {Privilege: ACLPrivilegeView, AuthMode: 4},
+			"PASE":                {Privilege: ACLPrivilegeView, AuthMode: ACLAuthModePASE},
+			"group administer":    {Privilege: ACLPrivilegeAdminister, AuthMode: ACLAuthModeGroup},
+			"zero subject":        {Privilege: ACLPrivilegeView, AuthMode: ACLAuthModeCASE, Subjects: []uint64{0}},
+			"empty target":        {Privilege: ACLPrivilegeView, AuthMode: ACLAuthModeCASE, Targets: []ACLTarget{{}}},
+			"endpoint and device": {Privilege: ACLPrivilegeView, AuthMode: ACLAuthModeCASE, Targets: []ACLTarget{{Endpoint: &endpoint, DeviceType: &deviceType}}},
+		} {
+			t.Run(name, func(t *testing.T) {
+				_, err := encodeACL([]ACLEntry{admin, entry})
+				assert.Error(t, err)
+			})
+		}
+	})
+
+	t.Run("no administer", func(t *testing.T) {
+		_, err := encodeACL([]ACLEntry{{Privilege: ACLPrivilegeView, AuthMode: ACLAuthModeCASE}})
+		assert.Error(t, err)
+	})
+
+	t.Run("administer of other node", func(t *testing.T) {
+		_, err := encodeACL([]ACLEntry{{
+			Privilege: ACLPrivilegeAdminister,
+			AuthMode:  ACLAuthModeCASE,
+			Subjects:  []uint64{445566},
+		}})
+		assert.Error(t, err)
+	})
+
+	t.Run("administer of any node", func(t *testing.T) {
+		_, err := encodeACL([]ACLEntry{{Privilege: ACLPrivilegeAdminister, AuthMode: ACLAuthModeCASE}})
+		assert.NoError(t, err)
+	})
+}
@@ -0,0 +1,91 @@
+package utils
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// SnapAlias is an alias of a snap's app, e.g. chip-tool for
+// matter-all-clusters-app.chip-tool
+type SnapAlias struct {
+	Command string // <snap>.<app>
+	Alias   string
+	Notes   string // e.g. manual, disabled, or - for an automatic alias
+}
+
+// SnapAliases returns the aliases of all installed snaps
+func SnapAliases(t *testing.T) []SnapAlias {
+	out, _, _ := Exec(t, "snap aliases")
+	return parseSnapAliases(out)
+}
+
+// parseSnapAliases parses the output of `snap aliases`:
+//
+//	Command                            Alias      Notes
+//	matter-all-clusters-app.chip-tool  chip-tool  manual
+func parseSnapAliases(out string) (aliases []SnapAlias) {
+	for _, fields := range snapTableRows(out, 3) {
+		aliases = append(aliases, SnapAlias{
+			Command: fields[0],
+			Alias:   fields[1],
+			Notes:   fields[2],
+		})
+	}
+	return aliases
+}
+
+// resolveSnapBin returns the snap app, <snap>.<app>, which a command in
+// /snap/bin runs, given the target of its symlink. Apps link to snap itself
+// and are named after their command, while aliases link to the app.
+func resolveSnapBin(command, target string) string {
+	if filepath.Base(target) == "snap" {
+		return command
+	}
+	return filepath.Base(target)
+}
+
+// ResolveCommand returns what the PATH of the harness's chip-tool commands,
+// i.e. sudo's, resolves a command to: the snap app, e.g. chip-tool or
+// matter-all-clusters-app.chip-tool, or the path of a binary outside of
+// /snap/bin. It is empty if the command isn't found.
+func ResolveCommand(t *testing.T, command string) string {
+	path, _, _ := Exec(t, "sudo sh -c 'command -v "+command+"' || true")
+	path = strings.TrimSpace(path)
+	if filepath.Dir(path) != "/snap/bin" {
+		return path
+	}
+
+	target, _, _ := Exec(t, "readlink "+path)
+	return resolveSnapBin(command, strings.TrimSpace(target))
+}
+
+// RequireChipToolResolution checks which of two snaps which both ship a
+// chip-tool provides the chip-tool command, e.g. via an alias of a device
+// snap's app, and that it is the expected one. Otherwise, the helpers may
+// silently exercise the wrong binary.
+func RequireChipToolResolution(t *testing.T, snapA, snapB, expected string) {
+	const command = "chip-tool"
+
+	for _, snap := range []string{snapA, snapB} {
+		if !SnapInstalled(t, snap) {
+			t.Fatalf("Snap %s is not installed", snap)
+		}
+	}
+	for _, a := range SnapAliases(t) {
+		if a.Alias == command {
+			t.Logf("Alias %s of %s (%s)", a.Alias, a.Command, a.Notes)
+		}
+	}
+
+	resolved := ResolveCommand(t, command)
+	if resolved == "" {
+		t.Fatalf("Command %s is not in the PATH", command)
+	}
+	snap, _, _ := strings.Cut(resolved, ".")
+	if strings.HasPrefix(resolved, "/") || snap != expected {
+		t.Fatalf("Command %s resolves to %s instead of snap %s (%s, %s installed)",
+			command, resolved, expected, snapA, snapB)
+	}
+	t.Logf("Command %s resolves to %s", command, resolved)
+}
@@ -0,0 +1,22 @@
+package utils
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSnapAliases(t *testing.T) {
+	aliases := parseSnapAliases(`Command                            Alias      Notes
+matter-all-clusters-app.chip-tool  chip-tool  manual
+`)
+	assert.Equal(t, []SnapAlias{{
+		Command: "matter-all-clusters-app.chip-tool",
+		Alias:   "chip-tool",
+		Notes:   "manual",
+	}}, aliases)
+
+	assert.Equal(t, "chip-tool", resolveSnapBin("chip-tool", "/usr/bin/snap"))
+	assert.Equal(t, "matter-all-clusters-app.chip-tool",
+		resolveSnapBin("chip-tool", "matter-all-clusters-app.chip-tool"))
+}
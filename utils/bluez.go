@@ -0,0 +1,179 @@
+package utils
+
+import (
+	"bufio"
+	goexec "os/exec"
+	"slices"
+	"strings"
+	"sync"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// BlueZEvent is a D-Bus message to or from BlueZ, e.g. a method call of
+// org.bluez.Device1.Connect
+type BlueZEvent struct {
+	Type      string // method_call, method_return, signal or error
+	Path      string
+	Interface string
+	Member    string
+	// content of the message, e.g. the changed properties of a signal
+	Body string
+}
+
+// Name returns the interface and member of the event, e.g.
+// org.bluez.Device1.Connect
+func (e BlueZEvent) Name() string {
+	return e.Interface + "." + e.Member
+}
+
+// BlueZCommissioningEvents are the BlueZ method calls of BLE commissioning
+// with chip-tool as central and a device app as peripheral on the same host
+var BlueZCommissioningEvents = []string{
+	// device app advertises and exposes the Matter GATT service
+	"org.bluez.LEAdvertisingManager1.RegisterAdvertisement",
+	"org.bluez.GattManager1.RegisterApplication",
+	// chip-tool discovers and connects to the device
+	"org.bluez.Adapter1.StartDiscovery",
+	"org.bluez.Device1.Connect",
+	// chip-tool writes to C1 and subscribes to C2 of the Matter service
+	"org.bluez.GattCharacteristic1.WriteValue",
+	"org.bluez.GattCharacteristic1.StartNotify",
+}
+
+// MonitorBlueZ captures the D-Bus traffic of BlueZ with `busctl monitor`
+// while running the operation, e.g. BLE commissioning, and returns the
+// messages. The capture is written to the log directory.
+func MonitorBlueZ(t *testing.T, operation func()) []BlueZEvent {
+	const command = "sudo busctl monitor --system org.bluez"
+	t.Logf("[exec] %s", command)
+
+	cmd := goexec.Command("/bin/bash", "-c", command)
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = startProcess(t, cmd); err != nil {
+		t.Fatalf("Error starting busctl: %s", err)
+	}
+
+	var mutex sync.Mutex
+	var capture strings.Builder
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		scanner := bufio.NewScanner(stdout)
+		for scanner.Scan() {
+			mutex.Lock()
+			capture.WriteString(scanner.Text() + "\n")
+			mutex.Unlock()
+		}
+		waitProcess(cmd)
+	}()
+
+	// give busctl a moment to subscribe
+	time.Sleep(1 * time.Second)
+	operation()
+
+	syscall.Kill(-cmd.Process.Pid, syscall.SIGTERM)
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+		<-done
+	}
+
+	mutex.Lock()
+	out := capture.String()
+	mutex.Unlock()
+
+	if path, err := WriteLogFile(t, "bluez-dbus", out); err != nil {
+		t.Logf("Error writing BlueZ D-Bus capture: %s", err)
+	} else {
+		t.Logf("Wrote BlueZ D-Bus capture to %s", path)
+	}
+	return parseBusctlMonitor(out)
+}
+
+// parseBusctlMonitor parses the messages of `busctl monitor`:
+//
+//	‣ Type=method_call  Endian=l  Flags=0  Version=1 Cookie=12  Timestamp="..."
+//	  Sender=:1.42  Destination=org.bluez  Path=/org/bluez/hci0/dev_AA_BB  Interface=org.bluez.Device1  Member=Connect
+//	  UniqueName=:1.42
+//	  MESSAGE "" {
+//	  };
+func parseBusctlMonitor(out string) (events []BlueZEvent) {
+	var body []string
+	inBody := false
+	flush := func() {
+		if len(events) > 0 {
+			events[len(events)-1].Body = strings.Join(body, "\n")
+		}
+		body = nil
+	}
+
+	for _, line := range strings.Split(out, "\n") {
+		trimmed := strings.TrimSpace(line)
+		switch {
+		case strings.HasPrefix(trimmed, "‣ Type="):
+			flush()
+			events = append(events, BlueZEvent{})
+			inBody = false
+		case len(events) == 0:
+			continue
+		case strings.HasPrefix(trimmed, "MESSAGE"):
+			inBody = true
+		}
+
+		if inBody {
+			body = append(body, trimmed)
+			continue
+		}
+		event := &events[len(events)-1]
+		for _, field := range strings.Fields(strings.TrimPrefix(trimmed, "‣")) {
+			key, value, _ := strings.Cut(field, "=")
+			switch key {
+			case "Type":
+				event.Type = value
+			case "Path":
+				event.Path = value
+			case "Interface":
+				event.Interface = value
+			case "Member":
+				event.Member = value
+			}
+		}
+	}
+	flush()
+	return events
+}
+
+// RequireBLECommissioningActivity monitors BlueZ during BLE commissioning,
+// e.g. with ChipToolPairBLEWiFi, and checks that the expected events, by
+// default BlueZCommissioningEvents, occurred. This shows how far the BLE
+// handshake got, beyond the chip-tool logs, before failing on a
+// commissioning error.
+func RequireBLECommissioningActivity(t *testing.T, commission func() error, expected ...string) []BlueZEvent {
+	if len(expected) == 0 {
+		expected = BlueZCommissioningEvents
+	}
+
+	var err error
+	events := MonitorBlueZ(t, func() {
+		err = commission()
+	})
+
+	for _, name := range expected {
+		if !slices.ContainsFunc(events, func(e BlueZEvent) bool { return e.Name() == name }) {
+			t.Errorf("No BlueZ %s during BLE commissioning", name)
+		}
+	}
+	if err != nil {
+		t.Errorf("Error commissioning over BLE: %s", err)
+	}
+	if t.Failed() {
+		t.FailNow()
+	}
+	return events
+}
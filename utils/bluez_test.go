@@ -0,0 +1,45 @@
+package utils
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseBusctlMonitor(t *testing.T) {
+	events := parseBusctlMonitor(`Monitoring bus message stream.
+‣ Type=method_call  Endian=l  Flags=0  Version=1 Cookie=12  Timestamp="Thu 2024-04-04 10:00:00.000000 UTC"
+  Sender=:1.42  Destination=org.bluez  Path=/org/bluez/hci0/dev_AA_BB_CC_DD_EE_FF  Interface=org.bluez.Device1  Member=Connect
+  UniqueName=:1.42
+  MESSAGE "" {
+  };
+
+‣ Type=signal  Endian=l  Flags=1  Version=1 Cookie=803  Timestamp="Thu 2024-04-04 10:00:01.000000 UTC"
+  Sender=:1.5  Path=/org/bluez/hci0/dev_AA_BB_CC_DD_EE_FF  Interface=org.freedesktop.DBus.Properties  Member=PropertiesChanged
+  UniqueName=:1.5
+  MESSAGE "sa{sv}as" {
+          STRING "org.bluez.Device1";
+          ARRAY "{sv}" {
+                  DICT_ENTRY "sv" {
+                          STRING "Connected";
+                          VARIANT "b" {
+                                  BOOLEAN true;
+                          };
+                  };
+          };
+          ARRAY "s" {
+          };
+  };
+`)
+	require.Len(t, events, 2)
+	assert.Equal(t, BlueZEvent{
+		Type:      "method_call",
+		Path:      "/org/bluez/hci0/dev_AA_BB_CC_DD_EE_FF",
+		Interface: "org.bluez.Device1",
+		Member:    "Connect",
+		Body:      "MESSAGE \"\" {\n};\n",
+	}, events[0])
+	assert.Equal(t, "org.freedesktop.DBus.Properties.PropertiesChanged", events[1].Name())
+	assert.Contains(t, events[1].Body, `STRING "Connected";`)
+}
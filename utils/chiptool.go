@@ -0,0 +1,321 @@
+package utils
+
+import (
+	"fmt"
+	"net"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+const (
+	// Default setup PIN code of the Matter example apps
+	DefaultSetupPINCode = "20202021"
+	// Default discriminator of the Matter example apps
+	DefaultDiscriminator = "3840"
+)
+
+// chipToolFailure is logged by chip-tool when a command fails
+const chipToolFailure = "Run command failure"
+
+// ChipTool runs a chip-tool command, e.g. "onoff toggle 110 1". Besides a
+// non-zero exit code, it treats a failure in chip-tool's logs as an error.
+func ChipTool(t *testing.T, args string) (stdout, stderr string, err error) {
+	stdout, stderr, err = ExecVerbose(t, "sudo chip-tool "+args)
+	if err == nil {
+		if err = checkChipToolExit(stdout+stderr, 0); err != nil && t != nil {
+			t.Fatalf("chip-tool %s: %s", args, err)
+		}
+	} else if code := ExitCode(err); code > 0 {
+		// only reached without t, which fails on a non-zero exit
+		if exitErr := checkChipToolExit(stdout+stderr, code); exitErr != nil {
+			err = exitErr
+		}
+	}
+	return stdout, stderr, err
+}
+
+// checkChipToolExit returns an error if chip-tool's exit code contradicts its
+// logs, i.e. it logged a failure but exited with 0, or exited with non-zero
+// without logging a failure, e.g. because it crashed
+func checkChipToolExit(output string, exitCode int) error {
+	failed := strings.Contains(output, chipToolFailure)
+	switch {
+	case exitCode == 0 && failed:
+		return fmt.Errorf("chip-tool exited with 0 but logged: %s", chipToolFailureLine(output))
+	case exitCode != 0 && !failed:
+		return fmt.Errorf("chip-tool exited with %d without logging a failure", exitCode)
+	}
+	return nil
+}
+
+// chipToolFailureLine returns the line of chip-tool's output with the failure
+func chipToolFailureLine(output string) string {
+	for _, line := range strings.Split(output, "\n") {
+		if strings.Contains(line, chipToolFailure) {
+			return strings.TrimSpace(line)
+		}
+	}
+	return ""
+}
+
+// RequireExitCode runs a chip-tool command and checks that it exits with the
+// expected code and that its logs agree, e.g. a command expected to fail with
+// 1 must also log a failure
+func RequireExitCode(t *testing.T, args string, expected int) (stdout, stderr string) {
+	stdout, stderr, _ = ExecExpect(t, "sudo chip-tool "+args, expected)
+	if err := checkChipToolExit(stdout+stderr, expected); err != nil {
+		t.Fatalf("chip-tool %s: %s", args, err)
+	}
+	return stdout, stderr
+}
+
+// ChipToolPairOnNetwork commissions a device discovered on the IP network.
+// The options are appended to the chip-tool command, e.g. the trace options
+// of ChipToolTrace.
+func ChipToolPairOnNetwork(t *testing.T, nodeID, pinCode string, options ...string) error {
+	_, stderr, err := ChipTool(t, strings.TrimSpace(fmt.Sprintf(
+		"pairing onnetwork %s %s %s",
+		nodeID,
+		pinCode,
+		strings.Join(options, " "),
+	)))
+	if err != nil {
+		return fmt.Errorf("%s: %s", err, stderr)
+	}
+	return nil
+}
+
+// ChipToolPairCode commissions a device using its QR code payload or manual
+// pairing code, e.g. as returned by WaitForSetupPayload
+func ChipToolPairCode(t *testing.T, nodeID, payload string) error {
+	_, stderr, err := ChipTool(t, fmt.Sprintf(
+		"pairing code %s %s",
+		nodeID,
+		payload,
+	))
+	if err != nil {
+		return fmt.Errorf("%s: %s", err, stderr)
+	}
+	return nil
+}
+
+// ChipToolPairOnNetworkLong commissions a device discovered on the IP network
+// with the given discriminator, to select one of several commissionable devices
+func ChipToolPairOnNetworkLong(t *testing.T, nodeID, pinCode, discriminator string) error {
+	_, stderr, err := ChipTool(t, fmt.Sprintf(
+		"pairing onnetwork-long %s %s %s",
+		nodeID,
+		pinCode,
+		discriminator,
+	))
+	if err != nil {
+		return fmt.Errorf("%s: %s", err, stderr)
+	}
+	return nil
+}
+
+// ChipToolPairAddress commissions a device at an explicit IPv6 address and
+// UDP port, bypassing discovery. Link-local addresses must include the scope,
+// e.g. fe80::1%eth0.
+func ChipToolPairAddress(t *testing.T, nodeID, pinCode, address, port string) error {
+	if err := validateIPv6Address(address); err != nil {
+		return err
+	}
+
+	_, stderr, err := ChipTool(t, fmt.Sprintf(
+		"pairing already-discovered %s %s %s %s",
+		nodeID,
+		pinCode,
+		address,
+		port,
+	))
+	if err != nil {
+		return fmt.Errorf("%s: %s", err, stderr)
+	}
+	return nil
+}
+
+func validateIPv6Address(address string) error {
+	host, zone, _ := strings.Cut(address, "%")
+	ip := net.ParseIP(host)
+	if ip == nil || ip.To4() != nil {
+		return fmt.Errorf("invalid IPv6 address: %s", address)
+	}
+	if ip.IsLinkLocalUnicast() && zone == "" {
+		return fmt.Errorf("link-local address %s has no scope, e.g. %%eth0", address)
+	}
+	if zone != "" {
+		if _, err := net.InterfaceByName(zone); err != nil {
+			return fmt.Errorf("invalid scope of %s: %s", address, err)
+		}
+	}
+	return nil
+}
+
+// ChipToolPairBLEThread commissions a device over BLE and provisions it onto
+// the Thread network described by the hex encoded operational dataset.
+// See ThreadDataset for generating a dataset.
+func ChipToolPairBLEThread(t *testing.T, nodeID, dataset, pinCode, discriminator string) error {
+	_, stderr, err := ChipTool(t, fmt.Sprintf(
+		"pairing ble-thread %s hex:%s %s %s",
+		nodeID,
+		dataset,
+		pinCode,
+		discriminator,
+	))
+	if err != nil {
+		return fmt.Errorf("%s: %s", err, stderr)
+	}
+	return nil
+}
+
+// ChipToolPairBLEWiFi commissions a device over BLE and provisions it onto
+// the WiFi network with the given SSID and passphrase
+func ChipToolPairBLEWiFi(t *testing.T, nodeID, ssid, password, pinCode, discriminator string) error {
+	_, stderr, err := ChipTool(t, fmt.Sprintf(
+		"pairing ble-wifi %s '%s' '%s' %s %s",
+		nodeID,
+		ssid,
+		password,
+		pinCode,
+		discriminator,
+	))
+	if err != nil {
+		return fmt.Errorf("%s: %s", err, stderr)
+	}
+	return nil
+}
+
+// ChipToolReadAttribute reads an attribute, e.g. cluster "onoff" and
+// attribute "on-off", and returns its value as printed by chip-tool
+func ChipToolReadAttribute(t *testing.T, nodeID, cluster, attribute, endpoint string) string {
+	stdout, _, _ := ChipTool(t, readAttributeCommand(nodeID, cluster, attribute, endpoint))
+
+	value, err := parseAttributeValue(stdout)
+	if err != nil {
+		t.Fatalf("Error reading %s %s: %s", cluster, attribute, err)
+	}
+	return value
+}
+
+func readAttributeCommand(nodeID, cluster, attribute, endpoint string) string {
+	return fmt.Sprintf("%s read %s %s %s", cluster, attribute, nodeID, endpoint)
+}
+
+// chip-tool output lines, with the old "CHIP:TOO:" or the new "[TOO]" prefix
+var chipToolLineExp = regexp.MustCompile(`(?:CHIP:TOO:|\[TOO\])\s+(.*)$`)
+
+// parseAttributeValue returns the value of the first attribute report in
+// chip-tool's output:
+//
+//	[TOO] Endpoint: 1 Cluster: 0x0000_0006 Attribute 0x0000_0000 DataVersion: 2658398485
+//	[TOO]   OnOff: TRUE
+func parseAttributeValue(output string) (string, error) {
+	foundReport := false
+	for _, line := range strings.Split(output, "\n") {
+		match := chipToolLineExp.FindStringSubmatch(line)
+		if match == nil {
+			continue
+		}
+		content := strings.TrimSpace(match[1])
+
+		if strings.HasPrefix(content, "Endpoint:") && strings.Contains(content, "Attribute") {
+			foundReport = true
+			continue
+		}
+
+		if foundReport {
+			_, value, found := strings.Cut(content, ":")
+			if !found {
+				return "", fmt.Errorf("unexpected attribute line: %s", content)
+			}
+			return strings.TrimSpace(value), nil
+		}
+	}
+	return "", fmt.Errorf("found no attribute report in output")
+}
+
+// chip-tool list entry, e.g. "[1]: 2" or "[1]: {"
+var listEntryExp = regexp.MustCompile(`^\[\d+\]:\s*(.*)$`)
+
+// chipToolLines returns the content of chip-tool's TOO output lines
+func chipToolLines(output string) (lines []string) {
+	for _, line := range strings.Split(output, "\n") {
+		if match := chipToolLineExp.FindStringSubmatch(line); match != nil {
+			lines = append(lines, strings.TrimSpace(match[1]))
+		}
+	}
+	return lines
+}
+
+// parseListValues returns the entries of a list attribute of scalar values:
+//
+//	[TOO]   PartsList: 2 entries
+//	[TOO]     [1]: 1
+//	[TOO]     [2]: 2
+func parseListValues(output string) (values []string) {
+	for _, line := range chipToolLines(output) {
+		if match := listEntryExp.FindStringSubmatch(line); match != nil && match[1] != "{" {
+			values = append(values, match[1])
+		}
+	}
+	return values
+}
+
+// parseNamedList returns the scalar entries of a list field of a response,
+// which may contain several lists:
+//
+//	[TOO]   GetGroupMembershipResponse: {
+//	[TOO]     capacity: 254
+//	[TOO]     groupList: 2 entries
+//	[TOO]       [1]: 257
+//	[TOO]       [2]: 258
+//	[TOO]    }
+func parseNamedList(output, name string) (values []string) {
+	lines := chipToolLines(output)
+	for i, line := range lines {
+		var count int
+		if n, _ := fmt.Sscanf(line, name+": %d entries", &count); n != 1 {
+			continue
+		}
+		for _, entry := range lines[i+1:] {
+			match := listEntryExp.FindStringSubmatch(entry)
+			if match == nil || len(values) == count {
+				break
+			}
+			values = append(values, match[1])
+		}
+		return values
+	}
+	return nil
+}
+
+// parseListStructs returns the top-level fields of the entries of a list
+// attribute of structs. Fields of nested structs and lists are skipped.
+//
+//	[TOO]   DeviceTypeList: 1 entries
+//	[TOO]     [1]: {
+//	[TOO]       DeviceType: 256
+//	[TOO]       Revision: 1
+//	[TOO]      }
+func parseListStructs(output string) (entries []map[string]string) {
+	depth := 0
+	for _, line := range chipToolLines(output) {
+		switch {
+		case strings.HasSuffix(line, "{"):
+			depth++
+			if depth == 1 && listEntryExp.MatchString(line) {
+				entries = append(entries, make(map[string]string))
+			}
+		case line == "}":
+			depth = max(depth-1, 0)
+		case depth == 1 && len(entries) > 0:
+			key, value, found := strings.Cut(line, ":")
+			if found {
+				entries[len(entries)-1][strings.TrimSpace(key)] = strings.TrimSpace(value)
+			}
+		}
+	}
+	return entries
+}
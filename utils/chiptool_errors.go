@@ -0,0 +1,190 @@
+package utils
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// e.g. "CHIP Error 0x00000032: Timeout"
+var chipErrorExp = regexp.MustCompile(`CHIP Error (0x[0-9A-Fa-f]+)`)
+
+// parseChipErrors returns the distinct CHIP error codes in chip-tool's output
+func parseChipErrors(output string) (codes []uint64) {
+	for _, match := range chipErrorExp.FindAllStringSubmatch(output, -1) {
+		code, err := strconv.ParseUint(match[1], 0, 32)
+		if err != nil {
+			continue
+		}
+		if !slices.Contains(codes, code) {
+			codes = append(codes, code)
+		}
+	}
+	return codes
+}
+
+// exit code of the timeout command if the command timed out
+const timeoutExitCode = 124
+
+// chipToolTimeoutCommand returns the command which runs chip-tool with the
+// arguments and kills it if it doesn't exit within the timeout, rounded up
+// to whole seconds
+func chipToolTimeoutCommand(timeout time.Duration, args string) string {
+	seconds := max(int(math.Ceil(timeout.Seconds())), 1)
+	return fmt.Sprintf("sudo timeout --kill-after=10 %d chip-tool %s", seconds, args)
+}
+
+// ChipToolPairExpectFailure attempts to commission a device with the given,
+// e.g. wrong, PIN code and checks that chip-tool exits with an error within a
+// timeout and reports the expected CHIP error code, e.g. 0x00000032. If
+// commissioning succeeds unexpectedly, the device is unpaired again.
+func ChipToolPairExpectFailure(t *testing.T, nodeID, pinCode, wantCode string) {
+	const timeout = 2 * time.Minute
+
+	want, err := strconv.ParseUint(wantCode, 0, 32)
+	if err != nil {
+		t.Fatalf("Invalid CHIP error code '%s': %s", wantCode, err)
+	}
+
+	stdout, stderr, err := ExecVerbose(nil, chipToolTimeoutCommand(timeout,
+		fmt.Sprintf("pairing onnetwork %s %s", nodeID, pinCode)))
+
+	switch {
+	case err == nil:
+		ChipToolUnpair(t, nodeID)
+		t.Fatalf("Commissioning with PIN code %s succeeded unexpectedly", pinCode)
+	case ExitCode(err) == timeoutExitCode:
+		t.Fatalf("Time out: commissioning with PIN code %s did not fail within %s", pinCode, timeout)
+	}
+
+	codes := parseChipErrors(stdout + stderr)
+	if slices.Contains(codes, want) {
+		t.Logf("Commissioning failed with expected CHIP error 0x%08X", want)
+		return
+	}
+
+	formatted := make([]string, len(codes))
+	for i, c := range codes {
+		formatted[i] = fmt.Sprintf("0x%08X", c)
+	}
+	t.Fatalf("Commissioning failed without CHIP error 0x%08X, found: %s", want, strings.Join(formatted, ", "))
+}
+
+// Interaction Model status codes, by the names chip-tool logs
+var imStatusCodes = map[string]uint64{
+	"SUCCESS":                  0x00,
+	"FAILURE":                  0x01,
+	"INVALID_SUBSCRIPTION":     0x7d,
+	"UNSUPPORTED_ACCESS":       0x7e,
+	"UNSUPPORTED_ENDPOINT":     0x7f,
+	"INVALID_ACTION":           0x80,
+	"UNSUPPORTED_COMMAND":      0x81,
+	"INVALID_COMMAND":          0x85,
+	"UNSUPPORTED_ATTRIBUTE":    0x86,
+	"CONSTRAINT_ERROR":         0x87,
+	"UNSUPPORTED_WRITE":        0x88,
+	"RESOURCE_EXHAUSTED":       0x89,
+	"NOT_FOUND":                0x8b,
+	"UNREPORTABLE_ATTRIBUTE":   0x8c,
+	"INVALID_DATA_TYPE":        0x8d,
+	"UNSUPPORTED_READ":         0x8f,
+	"DATA_VERSION_MISMATCH":    0x92,
+	"TIMEOUT":                  0x94,
+	"BUSY":                     0x9c,
+	"UNSUPPORTED_CLUSTER":      0xc3,
+	"NO_UPSTREAM_SUBSCRIPTION": 0xc5,
+	"NEEDS_TIMED_INTERACTION":  0xc6,
+	"UNSUPPORTED_EVENT":        0xc7,
+	"PATHS_EXHAUSTED":          0xc8,
+	"TIMED_REQUEST_MISMATCH":   0xc9,
+	"FAILSAFE_REQUIRED":        0xca,
+}
+
+// e.g. "IM Error 0x0000057F: General error: 0x7f (UNSUPPORTED_ENDPOINT)"
+// or "Received Command Response Status for Endpoint=1 ... Status=0x0"
+var imStatusExp = regexp.MustCompile(`IM Error 0x0000(05[0-9A-Fa-f]{2})|\bStatus=(0x[0-9A-Fa-f]+)`)
+
+// parseIMStatuses returns the distinct Interaction Model status codes of the
+// responses in chip-tool's output
+func parseIMStatuses(output string) (statuses []uint64) {
+	for _, match := range imStatusExp.FindAllStringSubmatch(output, -1) {
+		var status uint64
+		var err error
+		if match[1] != "" {
+			// the IM error range is 0x500 + status
+			status, err = strconv.ParseUint(match[1], 16, 16)
+			status -= 0x500
+		} else {
+			status, err = strconv.ParseUint(match[2], 0, 8)
+		}
+		if err == nil && !slices.Contains(statuses, status) {
+			statuses = append(statuses, status)
+		}
+	}
+	return statuses
+}
+
+// parseIMStatus parses a status given by name, e.g. UNSUPPORTED_ENDPOINT, or
+// code, e.g. 0x7f
+func parseIMStatus(status string) (uint64, error) {
+	if code, found := imStatusCodes[strings.ToUpper(status)]; found {
+		return code, nil
+	}
+	code, err := strconv.ParseUint(status, 0, 8)
+	if err != nil {
+		return 0, fmt.Errorf("invalid IM status '%s'", status)
+	}
+	return code, nil
+}
+
+// ChipToolExpectStatus runs a chip-tool command, e.g. one with an
+// out-of-range endpoint, and checks that the device responds with the
+// Interaction Model status, e.g. UNSUPPORTED_ENDPOINT or 0x7f, within a
+// timeout instead of hanging
+func ChipToolExpectStatus(t *testing.T, cmd string, wantStatus string) {
+	const timeout = 1 * time.Minute
+
+	want, err := parseIMStatus(wantStatus)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stdout, stderr, err := ExecVerbose(nil, chipToolTimeoutCommand(timeout, cmd))
+	if ExitCode(err) == timeoutExitCode {
+		t.Fatalf("Time out: chip-tool %s got no response within %s", cmd, timeout)
+	}
+
+	statuses := parseIMStatuses(stdout + stderr)
+	if slices.Contains(statuses, want) {
+		t.Logf("Device responded with status 0x%02x (%s)", want, wantStatus)
+		return
+	}
+
+	formatted := make([]string, len(statuses))
+	for i, s := range statuses {
+		formatted[i] = fmt.Sprintf("0x%02x", s)
+	}
+	t.Fatalf("chip-tool %s got no status 0x%02x (%s), found: [%s], error: %v",
+		cmd, want, wantStatus, strings.Join(formatted, ", "), err)
+}
+
+// RequireGracefulStatus runs ChipToolExpectStatus and checks that the device
+// snap survived the command, i.e. none of its binaries dumped core and its
+// services are still active
+func RequireGracefulStatus(t *testing.T, snap, cmd, wantStatus string) {
+	start := time.Now()
+	ChipToolExpectStatus(t, cmd, wantStatus)
+
+	if !SnapServicesActive(t, snap) {
+		t.Errorf("Services of %s are not active after chip-tool %s", snap, cmd)
+	}
+	RequireNoCoreDumps(t, snap, start)
+	if t.Failed() {
+		t.FailNow()
+	}
+}
@@ -0,0 +1,183 @@
+package utils
+
+import (
+	"fmt"
+	"io"
+	goexec "os/exec"
+	"strings"
+	"sync"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// prompt printed by chip-tool's interactive mode when ready for a command
+const chipToolPrompt = ">>> "
+
+// ChipToolSession is a long running chip-tool process in interactive mode.
+// It avoids the process startup and fabric loading for every command.
+type ChipToolSession struct {
+	cmd   *goexec.Cmd
+	stdin io.WriteCloser
+
+	// serializes commands
+	runMutex sync.Mutex
+
+	mutex  sync.Mutex
+	output strings.Builder
+	update chan struct{}
+	done   chan struct{}
+}
+
+// StartChipToolSession starts chip-tool in interactive mode and waits for it
+// to accept commands. The session is closed on test cleanup.
+func StartChipToolSession(t *testing.T) *ChipToolSession {
+	t.Helper()
+
+	const command = "sudo chip-tool interactive start"
+	t.Logf("[exec] %s", command)
+
+	s := &ChipToolSession{
+		cmd:    goexec.Command("/bin/bash", "-c", command),
+		update: make(chan struct{}, 1),
+		done:   make(chan struct{}),
+	}
+	stdin, err := s.cmd.StdinPipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.stdin = stdin
+
+	// interleave standard output and error, same as on a terminal
+	reader, writer := io.Pipe()
+	s.cmd.Stdout = writer
+	s.cmd.Stderr = writer
+
+	if err = startProcess(t, s.cmd); err != nil {
+		t.Fatal(err)
+	}
+
+	go s.read(reader)
+	go func() {
+		waitProcess(s.cmd)
+		writer.Close()
+	}()
+
+	t.Cleanup(func() {
+		if err := s.Close(); err != nil {
+			t.Logf("Error closing chip-tool session: %s", err)
+		}
+	})
+
+	if _, err := s.waitPrompt(0, 30*time.Second); err != nil {
+		t.Fatalf("Error starting chip-tool session: %s", err)
+	}
+	return s
+}
+
+// read collects the output of the process until it exits
+func (s *ChipToolSession) read(reader io.Reader) {
+	defer close(s.done)
+
+	buf := make([]byte, 4096)
+	for {
+		n, err := reader.Read(buf)
+		if n > 0 {
+			s.mutex.Lock()
+			s.output.Write(buf[:n])
+			s.mutex.Unlock()
+
+			select {
+			case s.update <- struct{}{}:
+			default:
+			}
+		}
+		if err != nil {
+			return
+		}
+	}
+}
+
+// waitPrompt waits until the output after the offset contains the prompt and
+// returns the output in between
+func (s *ChipToolSession) waitPrompt(offset int, timeout time.Duration) (string, error) {
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+
+	for {
+		s.mutex.Lock()
+		output := s.output.String()[offset:]
+		s.mutex.Unlock()
+
+		if i := strings.Index(output, chipToolPrompt); i != -1 {
+			return output[:i], nil
+		}
+
+		select {
+		case <-s.update:
+		case <-s.done:
+			return output, fmt.Errorf("chip-tool exited")
+		case <-timer.C:
+			return output, fmt.Errorf("Time out: waited %s for command to complete", timeout)
+		}
+	}
+}
+
+// Run sends a command to the session, e.g. "onoff toggle 110 1", and returns
+// its output once chip-tool is ready for the next command
+func (s *ChipToolSession) Run(t *testing.T, command string, timeout time.Duration) (string, error) {
+	if t != nil {
+		t.Helper()
+		t.Logf("[chip-tool interactive] %s", command)
+	}
+
+	s.runMutex.Lock()
+	defer s.runMutex.Unlock()
+
+	s.mutex.Lock()
+	offset := s.output.Len()
+	s.mutex.Unlock()
+
+	if _, err := io.WriteString(s.stdin, command+"\n"); err != nil {
+		return "", err
+	}
+
+	output, err := s.waitPrompt(offset, timeout)
+	if err != nil && t != nil && len(output) != 0 {
+		t.Logf("[output] %s", output)
+	}
+	return output, err
+}
+
+// ReadAttribute reads an attribute using the session.
+// See ChipToolReadAttribute.
+func (s *ChipToolSession) ReadAttribute(t *testing.T, nodeID, cluster, attribute, endpoint string) (string, error) {
+	output, err := s.Run(t, readAttributeCommand(nodeID, cluster, attribute, endpoint), 30*time.Second)
+	if err != nil {
+		return "", err
+	}
+	return parseAttributeValue(output)
+}
+
+// Close quits the interactive mode and waits for the process to exit
+func (s *ChipToolSession) Close() error {
+	select {
+	case <-s.done:
+		return nil
+	default:
+	}
+
+	io.WriteString(s.stdin, "quit\n")
+	s.stdin.Close()
+
+	select {
+	case <-s.done:
+		return nil
+	case <-time.After(5 * time.Second):
+		if err := syscall.Kill(-s.cmd.Process.Pid, syscall.SIGKILL); err != nil {
+			return err
+		}
+		<-s.done
+		return fmt.Errorf("Killed chip-tool after timing out on quit")
+	}
+}
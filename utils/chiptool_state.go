@@ -0,0 +1,62 @@
+package utils
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// ChipToolStorageDir is where the chip-tool snap keeps its persistent storage,
+// i.e. the chip_tool_*.ini files with the fabric and node keys
+var ChipToolStorageDir = "/var/snap/chip-tool/common"
+
+// ChipToolReset clears chip-tool's persistent storage, removing all fabrics
+// and commissioned nodes
+func ChipToolReset(t *testing.T) error {
+	_, stderr, err := ChipTool(t, "storage clear-all")
+	if err != nil {
+		return fmt.Errorf("%s: %s", err, stderr)
+	}
+	return nil
+}
+
+// chipToolStorageFiles returns the storage files which contain any keys
+func chipToolStorageFiles(t *testing.T) []string {
+	// The command should not return error even if nothing is found, hence the "|| true"
+	stdout, _, _ := Exec(t, fmt.Sprintf(
+		"sudo find %s -name 'chip_tool_*.ini' -exec grep -l '=' {} + 2>/dev/null || true",
+		ChipToolStorageDir,
+	))
+	return strings.Fields(stdout)
+}
+
+// RequireCleanChipToolState checks that chip-tool's persistent storage is
+// empty or absent, e.g. at setup to detect state left by another test.
+// Unlike ChipToolReset, it reports the contamination instead of clearing it.
+func RequireCleanChipToolState(t *testing.T) {
+	if files := chipToolStorageFiles(t); len(files) != 0 {
+		t.Fatalf("chip-tool storage is not clean, found state in: %s. "+
+			"A previous test may have skipped its cleanup.",
+			strings.Join(files, ", "))
+	}
+}
+
+// RequireNoResidualSessions checks that no chip-tool processes, e.g.
+// interactive sessions or subscriptions, are left running and that the
+// persistent storage holds no fabrics or nodes, i.e. that ChipToolReset and
+// the cleanup of the sessions worked. It is meant to be called on teardown.
+func RequireNoResidualSessions(t *testing.T) {
+	// The command should not return error even if nothing is found, hence the "|| true"
+	stdout, _, _ := Exec(t, "pgrep --list-full --exact chip-tool || true")
+	if processes := strings.TrimSpace(stdout); processes != "" {
+		t.Errorf("chip-tool processes are still running:\n%s", processes)
+	}
+
+	if files := chipToolStorageFiles(t); len(files) != 0 {
+		t.Errorf("chip-tool storage still has state after cleanup in: %s", strings.Join(files, ", "))
+	}
+
+	if t.Failed() {
+		t.FailNow()
+	}
+}
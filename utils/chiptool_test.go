@@ -0,0 +1,300 @@
+package utils
+
+import (
+	goexec "os/exec"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseAttributeValue(t *testing.T) {
+
+	t.Run("new log format", func(t *testing.T) {
+		value, err := parseAttributeValue(`
+[1712236307.960] [12345:12347] [DMG] ReportDataMessage =
+[1712236307.960] [12345:12347] [TOO] Endpoint: 1 Cluster: 0x0000_0006 Attribute 0x0000_0000 DataVersion: 2658398485
+[1712236307.960] [12345:12347] [TOO]   OnOff: TRUE
+`)
+		require.NoError(t, err)
+		assert.Equal(t, "TRUE", value)
+	})
+
+	t.Run("old log format", func(t *testing.T) {
+		value, err := parseAttributeValue(`
+[1690981161.301510][123:125] CHIP:TOO: Endpoint: 1 Cluster: 0x0000_0008 Attribute 0x0000_0000 DataVersion: 1
+[1690981161.301541][123:125] CHIP:TOO:   CurrentLevel: 254
+`)
+		require.NoError(t, err)
+		assert.Equal(t, "254", value)
+	})
+
+	t.Run("no report", func(t *testing.T) {
+		_, err := parseAttributeValue(`[1712236307.960] [12345:12347] [TOO] Run command failure`)
+		assert.Error(t, err)
+	})
+}
+
+func TestLatencyStats(t *testing.T) {
+	stats := newLatencyStats([]time.Duration{
+		3 * time.Millisecond,
+		1 * time.Millisecond,
+		2 * time.Millisecond,
+	})
+	assert.Equal(t, 3, stats.Samples)
+	assert.Equal(t, 1*time.Millisecond, stats.Min)
+	assert.Equal(t, 3*time.Millisecond, stats.Max)
+	assert.Equal(t, 2*time.Millisecond, stats.Avg)
+}
+
+func TestParseNetworks(t *testing.T) {
+	networks := parseNetworks(`
+[1712236307.960] [12345:12347] [TOO] Endpoint: 0 Cluster: 0x0000_0031 Attribute 0x0000_0001 DataVersion: 1
+[1712236307.960] [12345:12347] [TOO]   Networks: 2 entries
+[1712236307.960] [12345:12347] [TOO]     [1]: {
+[1712236307.960] [12345:12347] [TOO]       NetworkID: 4D7953534944
+[1712236307.960] [12345:12347] [TOO]       Connected: TRUE
+[1712236307.960] [12345:12347] [TOO]      }
+[1712236307.960] [12345:12347] [TOO]     [2]: {
+[1712236307.960] [12345:12347] [TOO]       NetworkID: 4F74686572
+[1712236307.960] [12345:12347] [TOO]       Connected: FALSE
+[1712236307.960] [12345:12347] [TOO]      }
+`)
+	assert.Equal(t, []CommissionedNetwork{
+		{NetworkID: "MySSID", Connected: true},
+		{NetworkID: "Other", Connected: false},
+	}, networks)
+}
+
+func TestParseNetworkInterfaces(t *testing.T) {
+	interfaces := parseNetworkInterfaces(`
+[1712236307.960] [12345:12347] [TOO] Endpoint: 0 Cluster: 0x0000_0033 Attribute 0x0000_0000 DataVersion: 1
+[1712236307.960] [12345:12347] [TOO]   NetworkInterfaces: 2 entries
+[1712236307.960] [12345:12347] [TOO]     [1]: {
+[1712236307.960] [12345:12347] [TOO]       Name: eth0
+[1712236307.960] [12345:12347] [TOO]       IsOperational: TRUE
+[1712236307.960] [12345:12347] [TOO]       OffPremiseServicesReachableIPv4: null
+[1712236307.960] [12345:12347] [TOO]       HardwareAddress: 0242AC110002
+[1712236307.960] [12345:12347] [TOO]       IPv4Addresses: 1 entries
+[1712236307.960] [12345:12347] [TOO]         [1]: AC110002
+[1712236307.960] [12345:12347] [TOO]       IPv6Addresses: 0 entries
+[1712236307.960] [12345:12347] [TOO]       Type: 2
+[1712236307.960] [12345:12347] [TOO]      }
+[1712236307.960] [12345:12347] [TOO]     [2]: {
+[1712236307.960] [12345:12347] [TOO]       Name: wlan0
+[1712236307.960] [12345:12347] [TOO]       IsOperational: FALSE
+[1712236307.960] [12345:12347] [TOO]       HardwareAddress: DCA632000001
+[1712236307.960] [12345:12347] [TOO]       IPv4Addresses: 0 entries
+[1712236307.960] [12345:12347] [TOO]       IPv6Addresses: 0 entries
+[1712236307.960] [12345:12347] [TOO]       Type: 1
+[1712236307.960] [12345:12347] [TOO]      }
+`)
+	assert.Equal(t, []NetworkInterface{
+		{Name: "eth0", IsOperational: true, HardwareAddress: "0242AC110002", Type: "2"},
+		{Name: "wlan0", IsOperational: false, HardwareAddress: "DCA632000001", Type: "1"},
+	}, interfaces)
+}
+
+func TestParseSetupPayload(t *testing.T) {
+	qrCode, manualCode := parseSetupPayload(`
+Jan 01 10:00:00 host matter-all-clusters-app.all-clusters-app[1234]: [1712236307.960][1234:1234] CHIP:SVR: SetupQRCode: [MT:-24J042C00KA0648G00]
+Jan 01 10:00:00 host matter-all-clusters-app.all-clusters-app[1234]: [1712236307.960][1234:1234] CHIP:SVR: Manual pairing code: [34970112332]
+`)
+	assert.Equal(t, "MT:-24J042C00KA0648G00", qrCode)
+	assert.Equal(t, "34970112332", manualCode)
+}
+
+func TestParseList(t *testing.T) {
+
+	t.Run("values", func(t *testing.T) {
+		values := parseListValues(`
+[1712236307.960] [12345:12347] [TOO] Endpoint: 0 Cluster: 0x0000_001D Attribute 0x0000_0003 DataVersion: 1
+[1712236307.960] [12345:12347] [TOO]   PartsList: 2 entries
+[1712236307.960] [12345:12347] [TOO]     [1]: 1
+[1712236307.960] [12345:12347] [TOO]     [2]: 2
+`)
+		assert.Equal(t, []string{"1", "2"}, values)
+	})
+
+	t.Run("structs", func(t *testing.T) {
+		entries := parseListStructs(`
+[1712236307.960] [12345:12347] [TOO] Endpoint: 1 Cluster: 0x0000_001D Attribute 0x0000_0000 DataVersion: 1
+[1712236307.960] [12345:12347] [TOO]   DeviceTypeList: 2 entries
+[1712236307.960] [12345:12347] [TOO]     [1]: {
+[1712236307.960] [12345:12347] [TOO]       DeviceType: 256
+[1712236307.960] [12345:12347] [TOO]       Revision: 1
+[1712236307.960] [12345:12347] [TOO]      }
+[1712236307.960] [12345:12347] [TOO]     [2]: {
+[1712236307.960] [12345:12347] [TOO]       DeviceType: 17
+[1712236307.960] [12345:12347] [TOO]       Nested: {
+[1712236307.960] [12345:12347] [TOO]         Revision: 9
+[1712236307.960] [12345:12347] [TOO]        }
+[1712236307.960] [12345:12347] [TOO]       Revision: 2
+[1712236307.960] [12345:12347] [TOO]      }
+`)
+		assert.Equal(t, []map[string]string{
+			{"DeviceType": "256", "Revision": "1"},
+			{"DeviceType": "17", "Revision": "2"},
+		}, entries)
+	})
+}
+
+func TestDecodeSpecVersion(t *testing.T) {
+	major, minor := decodeSpecVersion(0x01030000)
+	assert.Equal(t, 1, major)
+	assert.Equal(t, 3, minor)
+}
+
+func TestParseNamedList(t *testing.T) {
+	output := `
+[1712236307.960] [12345:12347] [TOO] Endpoint: 1 Cluster: 0x0000_0062 Command 0x0000_0006
+[1712236307.960] [12345:12347] [TOO]   GetSceneMembershipResponse: {
+[1712236307.960] [12345:12347] [TOO]     status: 0
+[1712236307.960] [12345:12347] [TOO]     capacity: 15
+[1712236307.960] [12345:12347] [TOO]     groupID: 257
+[1712236307.960] [12345:12347] [TOO]     sceneList: 2 entries
+[1712236307.960] [12345:12347] [TOO]       [1]: 1
+[1712236307.960] [12345:12347] [TOO]       [2]: 2
+[1712236307.960] [12345:12347] [TOO]     otherList: 1 entries
+[1712236307.960] [12345:12347] [TOO]       [1]: 9
+[1712236307.960] [12345:12347] [TOO]    }
+`
+	assert.Equal(t, []string{"1", "2"}, parseNamedList(output, "sceneList"))
+	assert.Equal(t, []string{"9"}, parseNamedList(output, "otherList"))
+	assert.Nil(t, parseNamedList(output, "groupList"))
+}
+
+func TestParseChipErrors(t *testing.T) {
+	codes := parseChipErrors(`
+[1712236307.960] [12345:12347] [CTL] Failed to establish PASE: CHIP Error 0x00000032: Timeout
+[1712236307.960] [12345:12347] [TOO] Run command failure: CHIP Error 0x00000032: Timeout
+[1712236307.960] [12345:12347] [SC] PASE failed: CHIP Error 0x0000004A: Invalid PASE parameter
+`)
+	assert.Equal(t, []uint64{0x32, 0x4a}, codes)
+}
+
+func TestChipToolTimeoutCommand(t *testing.T) {
+	assert.Equal(t, "sudo timeout --kill-after=10 60 chip-tool pairing onnetwork 110 20202021",
+		chipToolTimeoutCommand(time.Minute, "pairing onnetwork 110 20202021"))
+	// rounded up, to not time out before the given duration
+	assert.Equal(t, "sudo timeout --kill-after=10 2 chip-tool onoff read on-off 110 1",
+		chipToolTimeoutCommand(1500*time.Millisecond, "onoff read on-off 110 1"))
+	assert.Equal(t, "sudo timeout --kill-after=10 1 chip-tool onoff read on-off 110 1",
+		chipToolTimeoutCommand(500*time.Millisecond, "onoff read on-off 110 1"))
+}
+
+func TestValidateIPv6Address(t *testing.T) {
+	assert.NoError(t, validateIPv6Address("fd11:22::1"))
+	assert.NoError(t, validateIPv6Address("fe80::1%lo"))
+
+	assert.Error(t, validateIPv6Address("192.168.1.10"))
+	assert.Error(t, validateIPv6Address("not-an-address"))
+	assert.Error(t, validateIPv6Address("fe80::1"))
+	assert.Error(t, validateIPv6Address("fe80::1%no-such-interface"))
+}
+
+func TestBusyStatus(t *testing.T) {
+	assert.True(t, busyStatusExp.MatchString("[TOO] Run command failure: IM Error 0x00000602: Cluster-specific error: 0x02"))
+	assert.False(t, busyStatusExp.MatchString("[TOO] Run command failure: IM Error 0x00000602: Cluster-specific error: 0x03"))
+	assert.False(t, busyStatusExp.MatchString("[TOO] Run command failure: CHIP Error 0x00000032: Timeout"))
+}
+
+func TestMatterTime(t *testing.T) {
+	utc, err := parseMatterTime("757382400000000")
+	assert.NoError(t, err)
+	assert.Equal(t, time.Date(2024, time.January, 1, 0, 0, 0, 0, time.UTC), utc)
+	assert.Equal(t, uint64(757382400000000), matterTime(utc))
+
+	utc, err = parseMatterTime("null")
+	assert.NoError(t, err)
+	assert.True(t, utc.IsZero())
+}
+
+func TestParseTimeZones(t *testing.T) {
+	zones, err := parseTimeZones(`
+[1712236307.960] [12345:12347] [TOO] Endpoint: 0 Cluster: 0x0000_0038 Attribute 0x0000_0005 DataVersion: 1
+[1712236307.960] [12345:12347] [TOO]   TimeZone: 1 entries
+[1712236307.960] [12345:12347] [TOO]     [1]: {
+[1712236307.960] [12345:12347] [TOO]       Offset: 3600
+[1712236307.960] [12345:12347] [TOO]       ValidAt: 0
+[1712236307.960] [12345:12347] [TOO]       Name: Europe/Berlin
+[1712236307.960] [12345:12347] [TOO]      }
+`)
+	assert.NoError(t, err)
+	assert.Equal(t, []TimeZone{{Offset: time.Hour, ValidAt: matterEpoch, Name: "Europe/Berlin"}}, zones)
+}
+
+func TestHueDistance(t *testing.T) {
+	assert.Equal(t, 0, hueDistance(120, 120))
+	assert.Equal(t, 5, hueDistance(115, 120))
+	assert.Equal(t, 5, hueDistance(120, 115))
+	assert.Equal(t, 3, hueDistance(253, 1))
+	assert.Equal(t, 3, hueDistance(1, 253))
+}
+
+func TestCheckChipToolExit(t *testing.T) {
+	failure := "[1712236307.960] [1234:1234] [TOO] Run command failure: src/controller/CHIPDeviceController.cpp:123: CHIP Error 0x00000032: Timeout\n"
+	success := "[1712236307.960] [1234:1234] [TOO] Sending command to node 0x6e\n"
+
+	assert.NoError(t, checkChipToolExit(success, 0))
+	assert.NoError(t, checkChipToolExit(failure, 1))
+
+	err := checkChipToolExit("...\n"+failure, 0)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "CHIP Error 0x00000032")
+	assert.Error(t, checkChipToolExit(success, 139))
+
+	// a crash without a test, which returns the error
+	fakeExec(t, func(string) (string, error) {
+		return success, goexec.Command("/bin/sh", "-c", "exit 139").Run()
+	})
+	_, _, err = ChipTool(nil, "onoff toggle 110 1")
+	assert.EqualError(t, err, "chip-tool exited with 139 without logging a failure")
+}
+
+func TestMissingClusters(t *testing.T) {
+	serverList := parseListValues(`[1712236307.960] [1234:1234] [TOO] Endpoint: 1 Cluster: 0x0000_001D Attribute 0x0000_0001 DataVersion: 123
+[1712236307.960] [1234:1234] [TOO]   ServerList: 4 entries
+[1712236307.960] [1234:1234] [TOO]     [1]: 3
+[1712236307.960] [1234:1234] [TOO]     [2]: 4
+[1712236307.960] [1234:1234] [TOO]     [3]: 6
+[1712236307.960] [1234:1234] [TOO]     [4]: 29
+`)
+	require.Equal(t, []string{"3", "4", "6", "29"}, serverList)
+
+	missing, err := missingClusters(serverList, []string{"0x0006", "29", "0x0008"})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"0x0008"}, missing)
+
+	_, err = missingClusters(serverList, []string{"onoff"})
+	assert.Error(t, err)
+}
+
+func TestTraceMessages(t *testing.T) {
+	trace := `{"direction":"outbound","payload":{"protocol":"Secure Channel","type":"PBKDFParamRequest"}}
+{"direction":"inbound","payload":{"protocol":"Secure Channel","type":"PBKDFParamResponse"}}
+{"direction":"outbound","payload":{"protocol":"Secure Channel","type":"PASE_Pake1"}}
+{"direction":"outbound","payload":{"protocol":"Interaction Model","type":"ReadRequest"}}
+`
+	assert.Len(t, traceMessages(trace, "PBKDFParam"), 2)
+	assert.Len(t, traceMessages(trace, "pase"), 1)
+	assert.Empty(t, traceMessages(trace, "CASE_Sigma1"))
+}
+
+func TestParseIMStatuses(t *testing.T) {
+	statuses := parseIMStatuses(`[1712236307.960] [1234:1234] [TOO] Received Command Response Status for Endpoint=255 Cluster=0x0000_0006 Command=0x0000_0002 Status=0x7f
+[1712236307.960] [1234:1234] [TOO] Response Failure: IM Error 0x0000057F: General error: 0x7f (UNSUPPORTED_ENDPOINT)
+[1712236307.961] [1234:1234] [TOO] Response Failure: IM Error 0x000005C3: General error: 0xc3 (UNSUPPORTED_CLUSTER)
+`)
+	assert.Equal(t, []uint64{0x7f, 0xc3}, statuses)
+
+	code, err := parseIMStatus("unsupported_endpoint")
+	require.NoError(t, err)
+	assert.EqualValues(t, 0x7f, code)
+	code, err = parseIMStatus("0x87")
+	require.NoError(t, err)
+	assert.EqualValues(t, 0x87, code)
+	_, err = parseIMStatus("BROKEN")
+	assert.Error(t, err)
+}
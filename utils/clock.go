@@ -0,0 +1,58 @@
+package utils
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+// SetSystemClockOffset moves the system clock forward, or back for a negative
+// offset, with NTP synchronization disabled. The clock is restored on
+// cleanup, accounting for the time elapsed meanwhile, and NTP synchronization
+// is re-enabled if it was enabled before.
+func SetSystemClockOffset(t *testing.T, offset time.Duration) {
+	ntp, _, _ := Exec(t, "timedatectl show --property=NTP --value")
+	ntpEnabled := strings.TrimSpace(ntp) == "yes"
+
+	// time.Since uses the monotonic clock, which isn't affected by the change
+	setAt := time.Now()
+	t.Cleanup(func() {
+		now := setAt.Add(time.Since(setAt))
+		ExecVerbose(t, fmt.Sprintf("sudo date --set=@%d", now.Unix()))
+		if ntpEnabled {
+			ExecVerbose(t, "sudo timedatectl set-ntp true")
+		}
+		t.Logf("Restored system clock to %s", now.Format(time.RFC3339))
+	})
+
+	if ntpEnabled {
+		ExecVerbose(t, "sudo timedatectl set-ntp false")
+	}
+	ExecVerbose(t, fmt.Sprintf("sudo date --set=@%d", setAt.Add(offset).Unix()))
+	t.Logf("Moved system clock by %s to %s", offset, time.Now().Format(time.RFC3339))
+}
+
+// RequireCommissioningAtClockOffset moves the system clock by the offset and
+// commissions the device. Without wantCode, commissioning must succeed, e.g.
+// within the validity of the certificates. Otherwise it must fail with that
+// CHIP error code, e.g. a certificate validity error for a clock set before
+// the certificates' notBefore time.
+//
+// The controller and device share the system clock. Devices with a Last
+// Known Good Time may still accept certificates, see the Matter specification.
+func RequireCommissioningAtClockOffset(t *testing.T, nodeID string, offset time.Duration, wantCode string) {
+	SetSystemClockOffset(t, offset)
+
+	if wantCode != "" {
+		ChipToolPairExpectFailure(t, nodeID, DefaultSetupPINCode, wantCode)
+		return
+	}
+
+	if err := ChipToolPairOnNetwork(t, nodeID, DefaultSetupPINCode); err != nil {
+		t.Fatalf("Error commissioning with the clock moved by %s: %s", offset, err)
+	}
+	t.Cleanup(func() {
+		ChipToolUnpair(t, nodeID)
+	})
+}
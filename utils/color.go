@@ -0,0 +1,60 @@
+package utils
+
+import (
+	"fmt"
+	"strconv"
+	"testing"
+	"time"
+)
+
+// ChipToolColorControlMoveToHue moves the hue of a device's endpoint to the
+// given value, 0-254, along the shortest direction. The transition time is in
+// tenths of a second.
+func ChipToolColorControlMoveToHue(t *testing.T, nodeID, endpoint string, hue, transitionTime int) error {
+	const shortestDirection = 0
+
+	_, stderr, err := ChipTool(t, fmt.Sprintf(
+		"colorcontrol move-to-hue %d %d %d 0 0 %s %s",
+		hue,
+		shortestDirection,
+		transitionTime,
+		nodeID,
+		endpoint,
+	))
+	if err != nil {
+		return fmt.Errorf("%s: %s", err, stderr)
+	}
+	return nil
+}
+
+// ChipToolReadCurrentHue returns the CurrentHue attribute of a device's endpoint
+func ChipToolReadCurrentHue(t *testing.T, nodeID, endpoint string) int {
+	value := ChipToolReadAttribute(t, nodeID, "colorcontrol", "current-hue", endpoint)
+	hue, err := strconv.Atoi(value)
+	if err != nil {
+		t.Fatalf("Invalid hue '%s': %s", value, err)
+	}
+	return hue
+}
+
+// RequireHueNear waits until the current hue of a device's endpoint is within
+// the tolerance of the expected hue, e.g. for a transition to complete
+func RequireHueNear(t *testing.T, nodeID, endpoint string, expected, tolerance int, timeout time.Duration) {
+	var hue int
+	Eventually(t, timeout, 1*time.Second, func() bool {
+		hue = ChipToolReadCurrentHue(t, nodeID, endpoint)
+		return hueDistance(hue, expected) <= tolerance
+	}, "hue of node %s near %d±%d", nodeID, expected, tolerance)
+	t.Logf("Hue of node %s is %d, near %d", nodeID, hue, expected)
+}
+
+// hueDistance returns the distance between two hues, which wrap around
+// after 254
+func hueDistance(a, b int) int {
+	const hues = 255
+	d := (a - b) % hues
+	if d < 0 {
+		d = -d
+	}
+	return min(d, hues-d)
+}
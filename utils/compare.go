@@ -0,0 +1,162 @@
+package utils
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+// ScenarioResult is the outcome of a scenario run against one revision of a
+// device snap
+type ScenarioResult struct {
+	// values read from the device, e.g. "onoff on-off": "TRUE"
+	Attributes map[string]string
+	// durations of the scenario's steps, e.g. "toggle": 120ms
+	Latencies map[string]time.Duration
+}
+
+// RevisionRun is the run of a scenario against one revision
+type RevisionRun struct {
+	Instance string
+	Revision string
+	Result   ScenarioResult
+	// logs of the instance during the scenario
+	Logs string
+}
+
+// RevisionComparison is the result of running a scenario against two
+// revisions of a device snap
+type RevisionComparison struct {
+	A, B RevisionRun
+	// attributes that differ between the revisions
+	Differences []string
+}
+
+// LatencyChange returns the relative change of a step's latency from
+// revision A to B, e.g. 0.5 if B took 50% longer
+func (c RevisionComparison) LatencyChange(step string) float64 {
+	a, b := c.A.Result.Latencies[step], c.B.Result.Latencies[step]
+	if a == 0 {
+		return 0
+	}
+	return float64(b-a) / float64(a)
+}
+
+func (c RevisionComparison) String() string {
+	var s strings.Builder
+	fmt.Fprintf(&s, "A: %s revision %s, B: %s revision %s\n",
+		c.A.Instance, c.A.Revision, c.B.Instance, c.B.Revision)
+	steps := make([]string, 0, len(c.A.Result.Latencies))
+	for step := range c.A.Result.Latencies {
+		steps = append(steps, step)
+	}
+	slices.Sort(steps)
+	for _, step := range steps {
+		fmt.Fprintf(&s, "latency %s: %s -> %s (%+.0f%%)\n", step,
+			c.A.Result.Latencies[step], c.B.Result.Latencies[step], c.LatencyChange(step)*100)
+	}
+	for _, d := range c.Differences {
+		fmt.Fprintf(&s, "differs %s\n", d)
+	}
+	return s.String()
+}
+
+// CompareRevisions installs two revisions of a device snap as parallel
+// instances, <snap>_a and <snap>_b, and runs the same scenario against each
+// of them. A source is either a channel or the path of a local snap.
+//
+// The instances run one after another because the device apps would
+// otherwise conflict on their ports. Each instance is started, commissioned,
+// passed to the scenario and then stopped and decommissioned. Both revisions
+// remain installed until test cleanup.
+func CompareRevisions(t *testing.T, snapName, sourceA, sourceB string, scenario func(t *testing.T, nodeID string) ScenarioResult) RevisionComparison {
+	const nodeID = "110"
+
+	runs := make([]RevisionRun, 0, 2)
+	for _, r := range []struct{ key, source string }{{"a", sourceA}, {"b", sourceB}} {
+		instance := SnapInstanceName(snapName, r.key)
+		t.Cleanup(func() {
+			SnapRemove(t, instance)
+		})
+
+		var err error
+		if strings.HasSuffix(r.source, ".snap") {
+			err = SnapInstallInstanceFromFile(t, r.source, snapName, r.key)
+		} else {
+			err = SnapInstallInstanceFromStore(t, snapName, r.key, r.source)
+		}
+		if err != nil {
+			t.Fatalf("Error installing %s from %s: %s", instance, r.source, err)
+		}
+		SnapStop(t, instance)
+	}
+
+	for _, r := range []string{"a", "b"} {
+		instance := SnapInstanceName(snapName, r)
+		run := RevisionRun{
+			Instance: instance,
+			Revision: SnapRevision(t, instance),
+		}
+
+		t.Run("revision "+run.Revision, func(t *testing.T) {
+			t.Cleanup(func() {
+				SnapStop(t, instance)
+				ChipToolReset(t)
+			})
+
+			start := time.Now()
+			SnapStart(t, instance)
+			WaitForMDNS(t, MDNSCommissionable, 60)
+			if err := ChipToolPairOnNetwork(t, nodeID, DefaultSetupPINCode); err != nil {
+				t.Fatalf("Error commissioning %s: %s", instance, err)
+			}
+
+			run.Result = scenario(t, nodeID)
+			run.Logs = SnapLogs(t, start, instance)
+		})
+		runs = append(runs, run)
+	}
+	if t.Failed() {
+		t.FailNow()
+	}
+
+	comparison := RevisionComparison{
+		A:           runs[0],
+		B:           runs[1],
+		Differences: diffAttributes(runs[0].Result.Attributes, runs[1].Result.Attributes),
+	}
+	t.Logf("Revision comparison:\n%s", comparison)
+	return comparison
+}
+
+// diffAttributes returns the attributes with different values, in the form
+// "<attribute>: <value a> != <value b>"
+func diffAttributes(a, b map[string]string) (differences []string) {
+	names := make([]string, 0, len(a))
+	for name := range a {
+		names = append(names, name)
+	}
+	for name := range b {
+		if _, found := a[name]; !found {
+			names = append(names, name)
+		}
+	}
+	slices.Sort(names)
+
+	for _, name := range names {
+		valueA, foundA := a[name]
+		valueB, foundB := b[name]
+		switch {
+		case !foundA:
+			valueA = "<missing>"
+		case !foundB:
+			valueB = "<missing>"
+		}
+		if valueA != valueB {
+			differences = append(differences, fmt.Sprintf("%s: %s != %s", name, valueA, valueB))
+		}
+	}
+	return differences
+}
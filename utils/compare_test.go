@@ -0,0 +1,42 @@
+package utils
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestDiffAttributes(t *testing.T) {
+	a := map[string]string{
+		"onoff on-off":               "TRUE",
+		"basicinformation vendor-id": "65521",
+		"levelcontrol current-level": "254",
+	}
+	b := map[string]string{
+		"onoff on-off":               "FALSE",
+		"basicinformation vendor-id": "65521",
+		"colorcontrol current-hue":   "0",
+	}
+
+	require.Equal(t, []string{
+		"colorcontrol current-hue: <missing> != 0",
+		"levelcontrol current-level: 254 != <missing>",
+		"onoff on-off: TRUE != FALSE",
+	}, diffAttributes(a, b))
+
+	require.Empty(t, diffAttributes(a, a))
+}
+
+func TestLatencyChange(t *testing.T) {
+	c := RevisionComparison{
+		A: RevisionRun{Result: ScenarioResult{Latencies: map[string]time.Duration{
+			"toggle": 100 * time.Millisecond,
+		}}},
+		B: RevisionRun{Result: ScenarioResult{Latencies: map[string]time.Duration{
+			"toggle": 150 * time.Millisecond,
+		}}},
+	}
+	require.InDelta(t, 0.5, c.LatencyChange("toggle"), 0.001)
+	require.Zero(t, c.LatencyChange("read"))
+}
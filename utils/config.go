@@ -1,18 +1,28 @@
 package utils
 
 import (
+	"fmt"
+	"slices"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/require"
 )
 
 type Config struct {
 	TestAutoStart bool
+	// ports which should listen only on the loopback interface when set via
+	// the bind-address option
+	TestBindAddress []string
 }
 
 func TestConfig(t *testing.T, snapName string, conf Config) {
 	t.Run("config", func(t *testing.T) {
 		TestAutoStart(t, snapName, conf.TestAutoStart)
+		if len(conf.TestBindAddress) > 0 {
+			TestBindAddress(t, snapName, conf.TestBindAddress)
+		}
 	})
 }
 
@@ -48,3 +58,92 @@ func TestAutostartGlobal(t *testing.T, snapName string) {
 		require.False(t, SnapServicesActive(t, snapName))
 	})
 }
+
+// TestBindAddress sets the bind-address option to the loopback address and
+// checks that the service ports listen only there
+func TestBindAddress(t *testing.T, snapName string, ports []string) {
+	t.Run("bind address", func(t *testing.T) {
+		t.Cleanup(func() {
+			SnapUnset(t, snapName, "bind-address")
+			SnapRestart(t, snapName)
+		})
+
+		SnapSet(t, snapName, "bind-address", "127.0.0.1")
+		SnapRestart(t, snapName)
+		WaitServiceOnline(t, 60, ports...)
+
+		requireListenAllInterfaces(t, false, ports...)
+		requireListenLoopback(t, ports...)
+	})
+}
+
+// SetLogLevel sets the log-level option of a snap, e.g. to debug, and
+// restarts its services to apply it, unless already set. If expectedLog is
+// set, it waits for that log line, e.g. a debug-only message logged at
+// startup, to verify that the level took effect before relying on it.
+func SetLogLevel(t *testing.T, snapName, level, expectedLog string) {
+	const key = "log-level"
+
+	if SnapGet(t, snapName, key) == level {
+		t.Logf("Log level of %s is already %s", snapName, level)
+		return
+	}
+
+	SnapSet(t, snapName, key, level)
+	start := time.Now()
+	SnapRestart(t, snapName)
+
+	if expectedLog != "" {
+		WaitForLogMessage(t, snapName, expectedLog, start)
+	}
+}
+
+// RequireReloadOnHUP sets a snap option, sends SIGHUP to the snap's processes
+// and checks that the new value takes effect without a restart: the expected
+// log line appears, the processes keep their IDs and the port stays open.
+// The option is restored on cleanup.
+func RequireReloadOnHUP(t *testing.T, snapName, key, value, port, expectedLog string) {
+	const timeout = 10 * time.Second
+
+	portOpen := func() bool {
+		return slices.ContainsFunc(Listeners(t), func(l Listener) bool {
+			return l.Port == port
+		})
+	}
+
+	WaitPortListening(t, 60, port)
+	pids := SnapPIDs(t, snapName)
+	if len(pids) == 0 {
+		t.Fatalf("Snap %s has no running processes", snapName)
+	}
+
+	original := SnapGet(t, snapName, key)
+	t.Cleanup(func() {
+		if original == "" {
+			SnapUnset(t, snapName, key)
+		} else {
+			SnapSet(t, snapName, key, original)
+		}
+	})
+
+	start := time.Now()
+	SnapSet(t, snapName, key, value)
+	ExecVerbose(t, fmt.Sprintf("sudo kill -HUP %s", strings.Join(pids, " ")))
+
+	for deadline := start.Add(timeout); ; time.Sleep(200 * time.Millisecond) {
+		if !portOpen() {
+			t.Fatalf("Port %s closed while %s reloaded %s=%s", port, snapName, key, value)
+		}
+		if strings.Contains(SnapLogs(t, start, snapName), expectedLog) {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("Time out: %s did not log '%s' within %s of SIGHUP", snapName, expectedLog, timeout)
+		}
+	}
+
+	if after := SnapPIDs(t, snapName); !slices.Equal(after, pids) {
+		t.Fatalf("Snap %s restarted instead of reloading on SIGHUP: processes %v -> %v", snapName, pids, after)
+	}
+	t.Logf("Snap %s reloaded %s=%s on SIGHUP in %s", snapName, key, value, time.Since(start).Round(time.Millisecond))
+}
@@ -0,0 +1,176 @@
+package utils
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// ToggleVerify selects how ChipToolToggleAndVerify verifies a toggle.
+// The zero value verifies by reading the OnOff attribute.
+type ToggleVerify struct {
+	// Verify by waiting for the expected log of the device snap instead
+	DeviceSnap  string
+	ExpectedLog string
+}
+
+// ChipToolToggle toggles the OnOff cluster of a device's endpoint
+func ChipToolToggle(t *testing.T, nodeID, endpoint string) error {
+	_, stderr, err := ChipTool(t, fmt.Sprintf(
+		"onoff toggle %s %s",
+		nodeID,
+		endpoint,
+	))
+	if err != nil {
+		return fmt.Errorf("%s: %s", err, stderr)
+	}
+	return nil
+}
+
+// ChipToolToggleAndVerify toggles the OnOff cluster of a device's endpoint and
+// verifies that the device actuated, with retries
+func ChipToolToggleAndVerify(t *testing.T, nodeID, endpoint string, verify ToggleVerify) {
+	if verify.DeviceSnap != "" {
+		start := time.Now()
+		if err := ChipToolToggle(t, nodeID, endpoint); err != nil {
+			t.Fatalf("Error toggling: %s", err)
+		}
+		WaitForLogMessage(t, verify.DeviceSnap, verify.ExpectedLog, start)
+		return
+	}
+
+	before := ChipToolReadAttribute(t, nodeID, "onoff", "on-off", endpoint)
+	if err := ChipToolToggle(t, nodeID, endpoint); err != nil {
+		t.Fatalf("Error toggling: %s", err)
+	}
+
+	const maxRetry = 10
+	for i := 1; i <= maxRetry; i++ {
+		after := ChipToolReadAttribute(t, nodeID, "onoff", "on-off", endpoint)
+		if after != before {
+			t.Logf("OnOff toggled from %s to %s", before, after)
+			return
+		}
+		t.Logf("Retry %d/%d: Waiting for OnOff to change from %s", i, maxRetry, before)
+		time.Sleep(1 * time.Second)
+	}
+	t.Fatalf("Time out: OnOff stayed %s after toggle", before)
+}
+
+// RequireRapidToggles sends n toggles in quick succession using an
+// interactive chip-tool session and checks that the device stayed responsive
+// and ended up in the state expected from the parity of n
+func RequireRapidToggles(t *testing.T, nodeID, endpoint string, n int) {
+	const timeout = 30 * time.Second
+
+	session := StartChipToolSession(t)
+
+	before, err := session.ReadAttribute(t, nodeID, "onoff", "on-off", endpoint)
+	if err != nil {
+		t.Fatalf("Error reading OnOff: %s", err)
+	}
+
+	start := time.Now()
+	for i := 1; i <= n; i++ {
+		output, err := session.Run(t, fmt.Sprintf("onoff toggle %s %s", nodeID, endpoint), timeout)
+		if err != nil {
+			t.Fatalf("Toggle %d/%d failed: %s", i, n, err)
+		}
+		if strings.Contains(output, chipToolFailure) {
+			t.Fatalf("Toggle %d/%d failed: %s", i, n, output)
+		}
+	}
+	elapsed := time.Since(start)
+	t.Logf("Sent %d toggles in %s (%.1f/s)", n, elapsed, float64(n)/elapsed.Seconds())
+
+	after, err := session.ReadAttribute(t, nodeID, "onoff", "on-off", endpoint)
+	if err != nil {
+		t.Fatalf("Error reading OnOff after toggles: %s", err)
+	}
+
+	expected := before
+	if n%2 == 1 {
+		expected = map[string]string{"TRUE": "FALSE", "FALSE": "TRUE"}[before]
+	}
+	if after != expected {
+		t.Fatalf("OnOff is %s after %d toggles from %s, expected %s", after, n, before, expected)
+	}
+}
+
+// StartUpOnOff values of the OnOff cluster, applied when the device starts
+const (
+	StartUpOnOffOff      = "0"
+	StartUpOnOffOn       = "1"
+	StartUpOnOffToggle   = "2"
+	StartUpOnOffPrevious = "null"
+)
+
+// RequireStartUpOnOff writes the StartUpOnOff attribute of a device's
+// endpoint, restarts the device snap to simulate a power cycle and checks that
+// the OnOff attribute matches the startup behavior
+func RequireStartUpOnOff(t *testing.T, deviceSnap, nodeID, endpoint, startUpOnOff string) {
+	if _, stderr, err := ChipTool(t, fmt.Sprintf(
+		"onoff write start-up-on-off %s %s %s",
+		startUpOnOff,
+		nodeID,
+		endpoint,
+	)); err != nil {
+		t.Fatalf("Error writing StartUpOnOff: %s: %s", err, stderr)
+	}
+	if value := ChipToolReadAttribute(t, nodeID, "onoff", "start-up-on-off", endpoint); value != startUpOnOff {
+		t.Fatalf("StartUpOnOff is %s after writing %s", value, startUpOnOff)
+	}
+
+	before := ChipToolReadAttribute(t, nodeID, "onoff", "on-off", endpoint)
+
+	var expected string
+	switch startUpOnOff {
+	case StartUpOnOffOff:
+		expected = "FALSE"
+	case StartUpOnOffOn:
+		expected = "TRUE"
+	case StartUpOnOffToggle:
+		expected = map[string]string{"TRUE": "FALSE", "FALSE": "TRUE"}[before]
+	case StartUpOnOffPrevious:
+		expected = before
+	default:
+		t.Fatalf("Invalid StartUpOnOff %s", startUpOnOff)
+	}
+
+	SnapRestart(t, deviceSnap)
+	// wait for the restarted device to be operational again
+	WaitForMDNS(t, MDNSOperational, 60)
+
+	after := ChipToolReadAttribute(t, nodeID, "onoff", "on-off", endpoint)
+	if after != expected {
+		t.Fatalf("OnOff is %s after restart with StartUpOnOff %s from %s, expected %s",
+			after, startUpOnOff, before, expected)
+	}
+	t.Logf("OnOff is %s after restart with StartUpOnOff %s from %s", after, startUpOnOff, before)
+}
+
+// ChipToolIdentify starts the identification, e.g. blinking, of a device's
+// endpoint for the given number of seconds and verifies that the device's
+// IdentifyTime attribute counts down from it
+func ChipToolIdentify(t *testing.T, nodeID, endpoint string, durationSec int) {
+	if _, stderr, err := ChipTool(t, fmt.Sprintf(
+		"identify identify %d %s %s",
+		durationSec,
+		nodeID,
+		endpoint,
+	)); err != nil {
+		t.Fatalf("Error sending identify: %s: %s", err, stderr)
+	}
+
+	value := ChipToolReadAttribute(t, nodeID, "identify", "identify-time", endpoint)
+	remaining, err := strconv.Atoi(value)
+	if err != nil {
+		t.Fatalf("Invalid identify time '%s': %s", value, err)
+	}
+	if remaining <= 0 || remaining > durationSec {
+		t.Fatalf("Node %s has identify time %d after identifying for %d seconds", nodeID, remaining, durationSec)
+	}
+	t.Logf("Node %s is identifying, %d/%d seconds remaining", nodeID, remaining, durationSec)
+}
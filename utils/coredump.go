@@ -0,0 +1,58 @@
+package utils
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+// SnapCoreDumps returns the PIDs of the snap's processes which dumped core
+// since the given time
+func SnapCoreDumps(t *testing.T, snap string, since time.Time) []string {
+	// The command should not return error even if no core dumps are found, hence the "|| true"
+	stdout, _, _ := Exec(t, fmt.Sprintf(
+		"sudo coredumpctl list --since \"%s\" --no-legend --no-pager 2>/dev/null || true",
+		since.Format("2006-01-02 15:04:05"),
+	))
+	return parseCoreDumps(stdout, snap)
+}
+
+// parseCoreDumps returns the PIDs of core dumps of the snap's binaries:
+//
+//	Mon 2024-01-01 10:00:00 UTC 1234 0 0 SIGSEGV present /snap/<snap>/<rev>/bin/app 1.2M
+func parseCoreDumps(out, snap string) (pids []string) {
+	for _, line := range strings.Split(out, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 5 {
+			continue
+		}
+		for _, f := range fields[5:] {
+			if strings.HasPrefix(f, "/snap/"+snap+"/") {
+				pids = append(pids, fields[4])
+				break
+			}
+		}
+	}
+	return pids
+}
+
+// RequireNoCoreDumps fails if any of the snap's binaries dumped core since the
+// given time, writing the details of each to the log directory.
+// Crashes may otherwise go unnoticed when services are restarted automatically.
+// It is meant to be called on cleanup.
+func RequireNoCoreDumps(t *testing.T, snap string, since time.Time) {
+	pids := SnapCoreDumps(t, snap, since)
+	for _, pid := range pids {
+		info, _, _ := Exec(t, fmt.Sprintf("sudo coredumpctl info --no-pager %s || true", pid))
+		label := fmt.Sprintf("%s-coredump-%s", snap, pid)
+		path, err := WriteLogFile(t, label, info)
+		if err != nil {
+			t.Logf("Error writing core dump info: %s", err)
+		}
+		t.Errorf("Process %s of snap %s dumped core. See %s", pid, snap, path)
+	}
+	if t.Failed() {
+		t.FailNow()
+	}
+}
@@ -0,0 +1,14 @@
+package utils
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseCoreDumps(t *testing.T) {
+	pids := parseCoreDumps(`Mon 2024-01-01 10:00:00 UTC 1234 0 0 SIGSEGV present /snap/matter-all-clusters-app/42/bin/chip-all-clusters-app 1.2M
+Mon 2024-01-01 10:05:00 UTC 5678 1000 1000 SIGABRT present /usr/bin/python3.10 2.3M
+`, "matter-all-clusters-app")
+	assert.Equal(t, []string{"1234"}, pids)
+}
@@ -0,0 +1,85 @@
+package utils
+
+import (
+	"fmt"
+	"strconv"
+	"testing"
+)
+
+// ChipToolReadEndpoints returns the endpoints of a device, other than the
+// root endpoint 0, from the Descriptor cluster's PartsList attribute
+func ChipToolReadEndpoints(t *testing.T, nodeID string) []string {
+	stdout, _, _ := ChipTool(t, readAttributeCommand(nodeID, "descriptor", "parts-list", "0"))
+	return parseListValues(stdout)
+}
+
+// ChipToolReadDeviceTypes returns the device type IDs of an endpoint from the
+// Descriptor cluster's DeviceTypeList attribute, e.g. 256 for an On/Off Light
+func ChipToolReadDeviceTypes(t *testing.T, nodeID, endpoint string) (deviceTypes []string) {
+	stdout, _, _ := ChipTool(t, readAttributeCommand(nodeID, "descriptor", "device-type-list", endpoint))
+	for _, entry := range parseListStructs(stdout) {
+		deviceTypes = append(deviceTypes, entry["DeviceType"])
+	}
+	return deviceTypes
+}
+
+// RequireEndpointType checks that an endpoint has the device type, given in
+// decimal or hex, e.g. 256 or 0x0100 for an On/Off Light
+func RequireEndpointType(t *testing.T, nodeID, endpoint, deviceType string) {
+	expected, err := strconv.ParseUint(deviceType, 0, 32)
+	if err != nil {
+		t.Fatalf("Invalid device type '%s': %s", deviceType, err)
+	}
+
+	deviceTypes := ChipToolReadDeviceTypes(t, nodeID, endpoint)
+	for _, d := range deviceTypes {
+		if v, err := strconv.ParseUint(d, 0, 32); err == nil && v == expected {
+			return
+		}
+	}
+	t.Fatalf("Endpoint %s of node %s has device types %v, not %s", endpoint, nodeID, deviceTypes, deviceType)
+}
+
+// ChipToolReadServerList returns the IDs of the server clusters of an endpoint
+// from the Descriptor cluster's ServerList attribute, e.g. 6 for On/Off
+func ChipToolReadServerList(t *testing.T, nodeID, endpoint string) []string {
+	stdout, _, _ := ChipTool(t, readAttributeCommand(nodeID, "descriptor", "server-list", endpoint))
+	return parseListValues(stdout)
+}
+
+// missingClusters returns the cluster IDs, given in decimal or hex, which
+// aren't in the server list
+func missingClusters(serverList []string, clusterIDs []string) (missing []string, err error) {
+	present := make(map[uint64]bool)
+	for _, c := range serverList {
+		if v, err := strconv.ParseUint(c, 0, 32); err == nil {
+			present[v] = true
+		}
+	}
+
+	for _, c := range clusterIDs {
+		v, err := strconv.ParseUint(c, 0, 32)
+		if err != nil {
+			return nil, fmt.Errorf("invalid cluster ID '%s': %s", c, err)
+		}
+		if !present[v] {
+			missing = append(missing, c)
+		}
+	}
+	return missing, nil
+}
+
+// RequireClustersPresent checks that an endpoint implements the server
+// clusters, given in decimal or hex, e.g. 0x0006 and 0x0008 for a Dimmable
+// Light
+func RequireClustersPresent(t *testing.T, nodeID, endpoint string, clusterIDs ...string) {
+	serverList := ChipToolReadServerList(t, nodeID, endpoint)
+	missing, err := missingClusters(serverList, clusterIDs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(missing) != 0 {
+		t.Fatalf("Endpoint %s of node %s lacks server clusters %v, it has %v",
+			endpoint, nodeID, missing, serverList)
+	}
+}
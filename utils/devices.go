@@ -0,0 +1,146 @@
+package utils
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+)
+
+// VirtualDevice is a device app running as a parallel instance of its snap
+type VirtualDevice struct {
+	Instance      string // snap instance name, <snap>_<key>
+	NodeID        string
+	Discriminator string
+	Port          string // operational port
+}
+
+const (
+	virtualDeviceBaseNodeID        = 1000
+	virtualDeviceBaseDiscriminator = 3840
+	virtualDevicePort              = 5540
+)
+
+// CommissionVirtualDevices installs n parallel instances of a device snap,
+// starts and commissions each of them, and returns the commissioned devices.
+//
+// The configure callback applies the device's discriminator and port to the
+// instance, e.g. via `snap set`, before it gets started. It runs concurrently
+// for up to the given number of instances so it must return errors instead
+// of failing the test.
+//
+// Commissioning is sequential because chip-tool processes can't share the
+// same persistent storage concurrently.
+func CommissionVirtualDevices(t *testing.T, snapName string, n, concurrency int, configure func(device VirtualDevice) error) []VirtualDevice {
+	if err := SnapEnableParallelInstances(t); err != nil {
+		t.Fatalf("Error enabling parallel instances: %s", err)
+	}
+
+	devices := make([]VirtualDevice, n)
+	for i := range devices {
+		devices[i] = VirtualDevice{
+			Instance:      SnapInstanceName(snapName, strconv.Itoa(i+1)),
+			NodeID:        strconv.Itoa(virtualDeviceBaseNodeID + i + 1),
+			Discriminator: strconv.Itoa(virtualDeviceBaseDiscriminator + i + 1),
+			Port:          strconv.Itoa(virtualDevicePort + i + 1),
+		}
+	}
+
+	t.Cleanup(func() {
+		for _, d := range devices {
+			SnapRemove(t, d.Instance)
+		}
+	})
+
+	setup := func(d VirtualDevice) error {
+		if err := SnapInstallFromEnv(nil, d.Instance); err != nil {
+			return fmt.Errorf("install: %s", err)
+		}
+
+		if configure != nil {
+			if err := configure(d); err != nil {
+				return fmt.Errorf("configure: %s", err)
+			}
+		}
+
+		_, stderr, err := ExecVerbose(nil, "sudo snap start --enable "+d.Instance)
+		if err != nil {
+			return fmt.Errorf("start: %s: %s", err, stderr)
+		}
+		return nil
+	}
+
+	// set up the instances with bounded concurrency
+	errs := make([]error, n)
+	var wg sync.WaitGroup
+	semaphore := make(chan struct{}, max(concurrency, 1))
+	for i, d := range devices {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			semaphore <- struct{}{}
+			defer func() { <-semaphore }()
+			errs[i] = setup(d)
+		}()
+	}
+	wg.Wait()
+
+	for i, err := range errs {
+		if err != nil {
+			t.Errorf("Error setting up %s: %s", devices[i].Instance, err)
+		}
+	}
+	if t.Failed() {
+		t.FailNow()
+	}
+
+	for _, d := range devices {
+		if err := ChipToolPairOnNetworkLong(nil, d.NodeID, DefaultSetupPINCode, d.Discriminator); err != nil {
+			t.Errorf("Error commissioning %s as node %s: %s", d.Instance, d.NodeID, err)
+		}
+	}
+	if t.Failed() {
+		t.FailNow()
+	}
+
+	return devices
+}
+
+var (
+	setupQRCodeExp       = regexp.MustCompile(`SetupQRCode: \[(MT:[^\]]+)\]`)
+	manualPairingCodeExp = regexp.MustCompile(`Manual pairing code: \[(\d+)\]`)
+)
+
+// WaitForSetupPayload waits for an uncommissioned device snap to log its
+// setup payloads since the given time and returns the QR code payload,
+// e.g. MT:-24J042C00KA0648G00, and the manual pairing code, e.g. 34970112332.
+// See ChipToolPairCode for commissioning with either.
+func WaitForSetupPayload(t *testing.T, snap string, since time.Time) (qrCode, manualCode string) {
+	const maxRetry = 10
+
+	for i := 1; i <= maxRetry; i++ {
+		time.Sleep(1 * time.Second)
+		t.Logf("Retry %d/%d: Waiting for setup payload in logs", i, maxRetry)
+
+		qrCode, manualCode = parseSetupPayload(SnapLogs(t, since, snap))
+		if qrCode != "" && manualCode != "" {
+			t.Logf("Found setup payload: %s, manual pairing code: %s", qrCode, manualCode)
+			return qrCode, manualCode
+		}
+	}
+
+	t.Fatalf("Time out: reached max %d retries.", maxRetry)
+	return
+}
+
+func parseSetupPayload(logs string) (qrCode, manualCode string) {
+	if match := setupQRCodeExp.FindStringSubmatch(logs); match != nil {
+		qrCode = match[1]
+	}
+	if match := manualPairingCodeExp.FindStringSubmatch(logs); match != nil {
+		manualCode = match[1]
+	}
+	return qrCode, manualCode
+}
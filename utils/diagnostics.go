@@ -0,0 +1,79 @@
+package utils
+
+import (
+	"strconv"
+	"testing"
+)
+
+// GeneralDiagnostics holds the attributes of the General Diagnostics cluster
+type GeneralDiagnostics struct {
+	UpTime            uint64 // seconds since the device started
+	RebootCount       int
+	NetworkInterfaces []NetworkInterface
+}
+
+// NetworkInterface is an entry of the NetworkInterfaces attribute
+type NetworkInterface struct {
+	Name            string
+	IsOperational   bool
+	HardwareAddress string
+	Type            string // e.g. 1 for WiFi, 2 for Ethernet, 4 for Thread
+}
+
+// ChipToolReadDiagnostics reads the General Diagnostics cluster of a device
+func ChipToolReadDiagnostics(t *testing.T, nodeID string) GeneralDiagnostics {
+	var diagnostics GeneralDiagnostics
+	var err error
+
+	value := ChipToolReadAttribute(t, nodeID, "generaldiagnostics", "up-time", "0")
+	if diagnostics.UpTime, err = strconv.ParseUint(value, 10, 64); err != nil {
+		t.Fatalf("Invalid up time '%s': %s", value, err)
+	}
+
+	value = ChipToolReadAttribute(t, nodeID, "generaldiagnostics", "reboot-count", "0")
+	if diagnostics.RebootCount, err = strconv.Atoi(value); err != nil {
+		t.Fatalf("Invalid reboot count '%s': %s", value, err)
+	}
+
+	stdout, _, _ := ChipTool(t, readAttributeCommand(nodeID, "generaldiagnostics", "network-interfaces", "0"))
+	diagnostics.NetworkInterfaces = parseNetworkInterfaces(stdout)
+
+	t.Logf("Diagnostics of node %s: %+v", nodeID, diagnostics)
+	return diagnostics
+}
+
+// parseNetworkInterfaces parses the entries of the NetworkInterfaces attribute:
+//
+//	[TOO]   NetworkInterfaces: 1 entries
+//	[TOO]     [1]: {
+//	[TOO]       Name: eth0
+//	[TOO]       IsOperational: TRUE
+//	[TOO]       HardwareAddress: 0242AC110002
+//	[TOO]       IPv4Addresses: 1 entries
+//	[TOO]         [1]: AC110002
+//	[TOO]       Type: 2
+//	[TOO]      }
+func parseNetworkInterfaces(output string) (interfaces []NetworkInterface) {
+	for _, entry := range parseListStructs(output) {
+		interfaces = append(interfaces, NetworkInterface{
+			Name:            entry["Name"],
+			IsOperational:   entry["IsOperational"] == "TRUE",
+			HardwareAddress: entry["HardwareAddress"],
+			Type:            entry["Type"],
+		})
+	}
+	return interfaces
+}
+
+// RequireRebootCount checks the number of times the device has rebooted,
+// e.g. incremented by one after restarting the device snap
+func RequireRebootCount(t *testing.T, nodeID string, expected int) {
+	value := ChipToolReadAttribute(t, nodeID, "generaldiagnostics", "reboot-count", "0")
+	count, err := strconv.Atoi(value)
+	if err != nil {
+		t.Fatalf("Invalid reboot count '%s': %s", value, err)
+	}
+	if count != expected {
+		t.Fatalf("Node %s rebooted %d times instead of %d", nodeID, count, expected)
+	}
+}
@@ -0,0 +1,143 @@
+package utils
+
+import (
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// timeout of the timed interactions required by the Door Lock cluster's
+// credential and lock commands
+const doorLockTimedInteractionMs = 1000
+
+// DoorLockCredentialType is the type of a credential of the Door Lock cluster
+type DoorLockCredentialType int
+
+const (
+	DoorLockCredentialPIN  DoorLockCredentialType = 1
+	DoorLockCredentialRFID DoorLockCredentialType = 2
+)
+
+// DoorLockState is the LockState attribute of the Door Lock cluster
+type DoorLockState string
+
+const (
+	DoorLockStateNotFullyLocked DoorLockState = "0"
+	DoorLockStateLocked         DoorLockState = "1"
+	DoorLockStateUnlocked       DoorLockState = "2"
+)
+
+// DoorLockCredential is a credential to add with the set-credential command
+type DoorLockCredential struct {
+	Type  DoorLockCredentialType
+	Index int // slot of the credential, from 1
+	// the PIN digits, or the hex encoded RFID tag
+	Data string
+	// index of the user to add the credential to, or 0 to create a new user
+	UserIndex int
+}
+
+// validate applies the constraints of the Door Lock cluster, with the
+// default PIN length limits of the example lock app
+func (c DoorLockCredential) validate() error {
+	if c.Index < 1 {
+		return fmt.Errorf("invalid credential index %d", c.Index)
+	}
+	if c.UserIndex < 0 {
+		return fmt.Errorf("invalid user index %d", c.UserIndex)
+	}
+
+	switch c.Type {
+	case DoorLockCredentialPIN:
+		if len(c.Data) < 6 || len(c.Data) > 8 {
+			return fmt.Errorf("PIN must have 6 to 8 digits, got %d", len(c.Data))
+		}
+		if strings.Trim(c.Data, "0123456789") != "" {
+			return fmt.Errorf("PIN must only have digits")
+		}
+	case DoorLockCredentialRFID:
+		if _, err := hex.DecodeString(c.Data); err != nil || len(c.Data) == 0 {
+			return fmt.Errorf("RFID tag must be hex encoded")
+		}
+	default:
+		return fmt.Errorf("invalid credential type %d", c.Type)
+	}
+	return nil
+}
+
+// setCredentialArgs returns the arguments of the set-credential command,
+// adding the credential with user status and type null, i.e. the defaults
+func (c DoorLockCredential) setCredentialArgs() string {
+	const operationAdd = 0
+
+	data := c.Data
+	if c.Type == DoorLockCredentialPIN {
+		data = hex.EncodeToString([]byte(c.Data))
+	}
+	userIndex := "null"
+	if c.UserIndex != 0 {
+		userIndex = fmt.Sprint(c.UserIndex)
+	}
+
+	return fmt.Sprintf(`%d '{"credentialType": %d, "credentialIndex": %d}' hex:%s %s null null`,
+		operationAdd, c.Type, c.Index, data, userIndex)
+}
+
+// ChipToolSetCredential adds a credential to a door lock, creating a user for
+// it unless added to an existing one
+func ChipToolSetCredential(t *testing.T, nodeID, endpoint string, credential DoorLockCredential) error {
+	if err := credential.validate(); err != nil {
+		return fmt.Errorf("invalid credential: %s", err)
+	}
+
+	_, stderr, err := ChipTool(t, fmt.Sprintf(
+		"doorlock set-credential %s %s %s --timedInteractionTimeoutMs %d",
+		credential.setCredentialArgs(),
+		nodeID,
+		endpoint,
+		doorLockTimedInteractionMs,
+	))
+	if err != nil {
+		return fmt.Errorf("%s: %s", err, stderr)
+	}
+	return nil
+}
+
+// ChipToolLockDoor locks a door lock with a PIN, or without if empty
+func ChipToolLockDoor(t *testing.T, nodeID, endpoint, pinCode string) error {
+	return chipToolDoorLockCommand(t, "lock-door", nodeID, endpoint, pinCode)
+}
+
+// ChipToolUnlockDoor unlocks a door lock with a PIN, or without if empty
+func ChipToolUnlockDoor(t *testing.T, nodeID, endpoint, pinCode string) error {
+	return chipToolDoorLockCommand(t, "unlock-door", nodeID, endpoint, pinCode)
+}
+
+func chipToolDoorLockCommand(t *testing.T, command, nodeID, endpoint, pinCode string) error {
+	var pinOption string
+	if pinCode != "" {
+		pinOption = " --PINCode hex:" + hex.EncodeToString([]byte(pinCode))
+	}
+
+	_, stderr, err := ChipTool(t, fmt.Sprintf(
+		"doorlock %s %s %s --timedInteractionTimeoutMs %d%s",
+		command,
+		nodeID,
+		endpoint,
+		doorLockTimedInteractionMs,
+		pinOption,
+	))
+	if err != nil {
+		return fmt.Errorf("%s: %s", err, stderr)
+	}
+	return nil
+}
+
+// RequireLockState checks the LockState attribute of a door lock
+func RequireLockState(t *testing.T, nodeID, endpoint string, expected DoorLockState) {
+	state := DoorLockState(ChipToolReadAttribute(t, nodeID, "doorlock", "lock-state", endpoint))
+	if state != expected {
+		t.Fatalf("Door lock %s has lock state %s instead of %s", nodeID, state, expected)
+	}
+}
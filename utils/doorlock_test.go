@@ -0,0 +1,35 @@
+package utils
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDoorLockCredential(t *testing.T) {
+	pin := DoorLockCredential{Type: DoorLockCredentialPIN, Index: 1, Data: "123456"}
+
+	t.Run("set credential args", func(t *testing.T) {
+		assert.NoError(t, pin.validate())
+		assert.Equal(t, `0 '{"credentialType": 1, "credentialIndex": 1}' hex:313233343536 null null null`,
+			pin.setCredentialArgs())
+
+		rfid := DoorLockCredential{Type: DoorLockCredentialRFID, Index: 2, Data: "0a1b2c3d", UserIndex: 1}
+		assert.NoError(t, rfid.validate())
+		assert.Equal(t, `0 '{"credentialType": 2, "credentialIndex": 2}' hex:0a1b2c3d 1 null null`,
+			rfid.setCredentialArgs())
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		for _, c := range []DoorLockCredential{
+			{Type: DoorLockCredentialPIN, Index: 0, Data: "123456"},
+			{Type: DoorLockCredentialPIN, Index: 1, Data: "1234"},
+			{Type: DoorLockCredentialPIN, Index: 1, Data: "12345a"},
+			{Type: DoorLockCredentialRFID, Index: 1, Data: "xyz"},
+			{Type: 9, Index: 1, Data: "123456"},
+			{Type: DoorLockCredentialPIN, Index: 1, Data: "123456", UserIndex: -1},
+		} {
+			assert.Error(t, c.validate(), "%+v", c)
+		}
+	})
+}
@@ -4,6 +4,7 @@ import (
 	"bufio"
 	"context"
 	"errors"
+	"fmt"
 	"io"
 	"log"
 	goexec "os/exec"
@@ -11,39 +12,86 @@ import (
 	"testing"
 )
 
+// execFunc executes the commands of all helpers. Unit tests replace it to
+// return canned outputs of e.g. snap, lsof or journalctl, without root or
+// real snaps.
+var execFunc = execCommand
+
 func Exec(t *testing.T, command string) (stdout, stderr string, err error) {
 	if t != nil {
 		t.Helper()
 	}
-	return exec(t, nil, command, false)
+	return execFunc(t, nil, command, false, true)
 }
 
 func ExecVerbose(t *testing.T, command string) (stdout, stderr string, err error) {
 	if t != nil {
 		t.Helper()
 	}
-	return exec(t, nil, command, true)
+	return execFunc(t, nil, command, true, true)
 }
 
 func ExecContext(t *testing.T, ctx context.Context, command string) (stdout, stderr string, err error) {
 	if t != nil {
 		t.Helper()
 	}
-	return exec(t, ctx, command, false)
+	return execFunc(t, ctx, command, false, true)
 }
 
 func ExecContextVerbose(t *testing.T, ctx context.Context, command string) (stdout, stderr string, err error) {
 	if t != nil {
 		t.Helper()
 	}
-	return exec(t, ctx, command, true)
+	return execFunc(t, ctx, command, true, true)
+}
+
+// ExecExpect executes a command and checks that it exits with the expected
+// code, e.g. 1 for a command which must fail. The output is logged like with
+// ExecVerbose, but a failing command doesn't fail the test by itself. It
+// fails the test on a different code, or returns an error if t is nil.
+func ExecExpect(t *testing.T, command string, exitCode int) (stdout, stderr string, err error) {
+	if t != nil {
+		t.Helper()
+	}
+
+	stdout, stderr, err = execFunc(t, nil, command, true, false)
+	if code := ExitCode(err); code != exitCode {
+		err = fmt.Errorf("Command exited with code %d instead of %d: %s: %s", code, exitCode, command, stderr)
+		if t != nil {
+			t.Fatal(err)
+		}
+		return stdout, stderr, err
+	}
+	return stdout, stderr, nil
+}
+
+// ExitCode returns the exit code of a command from its error: 0 if nil and -1
+// if the command didn't exit, e.g. it couldn't start
+func ExitCode(err error) int {
+	if err == nil {
+		return 0
+	}
+	var exitErr *goexec.ExitError
+	if errors.As(err, &exitErr) {
+		return exitErr.ExitCode()
+	}
+	return -1
 }
 
-// exec executes a command
+// exec executes a command and fails the test if it fails
 func exec(t *testing.T, ctx context.Context, command string, verbose bool) (stdout, stderr string, err error) {
 	if t != nil {
 		t.Helper()
 	}
+	return execCommand(t, ctx, command, verbose, true)
+}
+
+// execCommand executes a command, logging via t if given. If fatal is set,
+// errors fail the test instead of being returned.
+func execCommand(t *testing.T, ctx context.Context, command string, verbose, fatal bool) (stdout, stderr string, err error) {
+	if t != nil {
+		t.Helper()
+	}
 
 	if t != nil {
 		t.Logf("[exec] %s", command)
@@ -63,7 +111,7 @@ func exec(t *testing.T, ctx context.Context, command string, verbose bool) (stdo
 	// standard output
 	outStream, err := cmd.StdoutPipe()
 	if err != nil {
-		if t != nil {
+		if t != nil && fatal {
 			t.Fatal(err)
 		} else {
 			return "", "", err
@@ -75,7 +123,7 @@ func exec(t *testing.T, ctx context.Context, command string, verbose bool) (stdo
 	// standard error
 	errStream, err := cmd.StderrPipe()
 	if err != nil {
-		if t != nil {
+		if t != nil && fatal {
 			t.Fatal(err)
 		} else {
 			return "", "", err
@@ -85,8 +133,8 @@ func exec(t *testing.T, ctx context.Context, command string, verbose bool) (stdo
 	go scanStdPipe(t, errStream, &stderr, &wg, verbose, "[stderr]")
 
 	// start execution
-	if err = cmd.Start(); err != nil {
-		if t != nil {
+	if err = startCommand(t, cmd); err != nil {
+		if t != nil && fatal {
 			t.Fatal(err)
 		} else {
 			return stdout, stderr, err
@@ -97,7 +145,7 @@ func exec(t *testing.T, ctx context.Context, command string, verbose bool) (stdo
 	wg.Wait()
 
 	// wait until command exits
-	if err = cmd.Wait(); err != nil {
+	if err = waitProcess(cmd); err != nil {
 		if ctx != nil &&
 			(errors.Is(ctx.Err(), context.Canceled) || errors.Is(ctx.Err(), context.DeadlineExceeded)) {
 			// cancelled by caller: do no error out
@@ -112,7 +160,9 @@ func exec(t *testing.T, ctx context.Context, command string, verbose bool) (stdo
 					t.Logf("[stderr] %s", stderr)
 				}
 			}
-			t.Fatal(err)
+			if fatal {
+				t.Fatal(err)
+			}
 		}
 		return stdout, stderr, err
 	}
@@ -2,6 +2,8 @@ package utils
 
 import (
 	"context"
+	"errors"
+	"strings"
 	"testing"
 	"time"
 
@@ -75,3 +77,70 @@ func TestExec(t *testing.T) {
 		require.NoError(t, err)
 	})
 }
+
+// fakeExec replaces the execution of commands until test cleanup. The handler
+// returns the canned output of a command. Like exec, a failing command fails
+// the test if the helper passed a testing.T, unless it expects the failure.
+func fakeExec(t *testing.T, handler func(command string) (stdout string, err error)) {
+	t.Cleanup(func() {
+		execFunc = execCommand
+	})
+	execFunc = func(ht *testing.T, _ context.Context, command string, _, fatal bool) (string, string, error) {
+		stdout, err := handler(command)
+		if err != nil && ht != nil && fatal {
+			ht.Fatal(err)
+		}
+		return stdout, "", err
+	}
+}
+
+func TestFakeExec(t *testing.T) {
+
+	t.Run("canned output", func(t *testing.T) {
+		fakeExec(t, func(command string) (string, error) {
+			if !strings.HasPrefix(command, "sudo lsof") {
+				return "", errors.New("unexpected command: " + command)
+			}
+			return `COMMAND     PID USER   FD   TYPE DEVICE SIZE/OFF NODE NAME
+chip-all- 12345 root    7u  IPv6 123456      0t0  UDP *:5540
+`, nil
+		})
+
+		listeners := Listeners(t)
+		require.Len(t, listeners, 1)
+		assert.Equal(t, "5540/udp", listeners[0].key())
+	})
+
+	t.Run("retry until advertised", func(t *testing.T) {
+		var browses int
+		fakeExec(t, func(command string) (string, error) {
+			browses++
+			if browses < 2 {
+				return "", nil
+			}
+			return `=;eth0;IPv6;2906C908D115D362;_matterc._udp;local;host.local;fe80::1;5540;"D=3840" "CM=1"`, nil
+		})
+
+		services := WaitForMDNS(t, MDNSCommissionable, 3)
+		require.Len(t, services, 1)
+		assert.Equal(t, "3840", services[0].TXT["D"])
+		assert.Equal(t, 2, browses)
+	})
+}
+
+func TestExitCode(t *testing.T) {
+	_, _, err := exec(nil, nil, `exit 3`, false)
+	assert.Equal(t, 3, ExitCode(err))
+	assert.Equal(t, 0, ExitCode(nil))
+	assert.Equal(t, -1, ExitCode(errors.New("not started")))
+
+	_, _, err = ExecExpect(nil, `exit 3`, 3)
+	assert.NoError(t, err)
+	_, _, err = ExecExpect(nil, `true`, 3)
+	assert.Error(t, err)
+
+	// the expected failure doesn't fail the test
+	stdout, _, err := ExecExpect(t, `echo "failing" && exit 3`, 3)
+	assert.NoError(t, err)
+	assert.Equal(t, "failing\n", stdout)
+}
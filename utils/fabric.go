@@ -0,0 +1,123 @@
+package utils
+
+import (
+	"fmt"
+	"log"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// ChipToolReadFabricCount returns the number of fabrics a device is
+// commissioned into, from the Operational Credentials cluster
+func ChipToolReadFabricCount(t *testing.T, nodeID string) int {
+	value := ChipToolReadAttribute(t, nodeID, "operationalcredentials", "commissioned-fabrics", "0")
+	count, err := strconv.Atoi(value)
+	if err != nil {
+		t.Fatalf("Invalid fabric count '%s': %s", value, err)
+	}
+	return count
+}
+
+// RequireFabricCount checks the number of fabrics a device is commissioned into
+func RequireFabricCount(t *testing.T, nodeID string, expected int) {
+	if count := ChipToolReadFabricCount(t, nodeID); count != expected {
+		t.Fatalf("Node %s is commissioned into %d fabrics instead of %d", nodeID, count, expected)
+	}
+}
+
+// ChipToolReadSpecVersion returns the Matter specification version of a
+// device, e.g. 1.3, from the Basic Information cluster
+func ChipToolReadSpecVersion(t *testing.T, nodeID string) (major, minor int) {
+	value := ChipToolReadAttribute(t, nodeID, "basicinformation", "specification-version", "0")
+	encoded, err := strconv.ParseUint(value, 0, 32)
+	if err != nil {
+		t.Fatalf("Invalid specification version '%s': %s", value, err)
+	}
+	return decodeSpecVersion(uint32(encoded))
+}
+
+// decodeSpecVersion decodes the uint32 encoded specification version, made of
+// major, minor, patch and reserved bytes, e.g. 0x01030000 for 1.3
+func decodeSpecVersion(encoded uint32) (major, minor int) {
+	return int(encoded >> 24), int(encoded >> 16 & 0xff)
+}
+
+// RequireSpecVersionAtLeast checks that a device implements at least the
+// given Matter specification version, e.g. 1.2
+func RequireSpecVersionAtLeast(t *testing.T, nodeID, version string) {
+	major, minor := ChipToolReadSpecVersion(t, nodeID)
+	actual := fmt.Sprintf("%d.%d", major, minor)
+	t.Logf("Node %s implements Matter specification %s", nodeID, actual)
+
+	if compareVersions(actual, version) < 0 {
+		t.Fatalf("Node %s implements Matter specification %s, older than %s", nodeID, actual, version)
+	}
+}
+
+// ChipToolUnpair removes a commissioned device from chip-tool's fabric.
+// If the device is offline, i.e. chip-tool fails with a CHIP timeout error,
+// the error is logged and chip-tool's own state of the node is removed
+// regardless.
+func ChipToolUnpair(t *testing.T, nodeID string) error {
+	const timeout = 2 * time.Minute
+
+	stdout, stderr, err := ExecVerbose(nil, chipToolTimeoutCommand(timeout, "pairing unpair "+nodeID))
+	if err == nil {
+		return nil
+	}
+	if ExitCode(err) == timeoutExitCode {
+		return fmt.Errorf("time out: unpairing node %s did not complete within %s", nodeID, timeout)
+	}
+
+	if slices.Contains(parseChipErrors(stdout+stderr), chipErrorTimeout) {
+		msg := fmt.Sprintf("Node %s seems offline, removed from chip-tool only: %s", nodeID, err)
+		if t != nil {
+			t.Log(msg)
+		} else {
+			log.Print(msg)
+		}
+		return nil
+	}
+	return fmt.Errorf("%s: %s", err, stderr)
+}
+
+// RequireUnpaired checks that a device was removed from chip-tool's fabric:
+// a read over CASE fails and the device no longer advertises itself as an
+// operational node of the fabric. The device's Fabrics attribute can't be
+// read for this because the fabric's session keys are gone.
+func RequireUnpaired(t *testing.T, nodeID string) {
+	id, err := strconv.ParseUint(nodeID, 0, 64)
+	if err != nil {
+		t.Fatalf("Invalid node ID '%s': %s", nodeID, err)
+	}
+
+	if _, _, err := ExecVerbose(nil, chipToolTimeoutCommand(time.Minute,
+		readAttributeCommand(nodeID, "operationalcredentials", "fabrics", "0"))); err == nil {
+		t.Fatalf("Node %s can still be read over CASE after unpairing", nodeID)
+	}
+
+	// operational instance names are <compressed fabric ID>-<node ID> in hex
+	suffix := fmt.Sprintf("-%016X", id)
+
+	// allow the device to withdraw its advertisement
+	const maxRetry = 10
+	for i := 1; i <= maxRetry; i++ {
+		advertised := false
+		for _, s := range BrowseMDNS(t, MDNSOperational) {
+			if strings.HasSuffix(s.Name, suffix) {
+				advertised = true
+				break
+			}
+		}
+		if !advertised {
+			t.Logf("Node %s is no longer advertised as operational", nodeID)
+			return
+		}
+		t.Logf("Retry %d/%d: Waiting for node %s to stop advertising", i, maxRetry, nodeID)
+		time.Sleep(1 * time.Second)
+	}
+	t.Fatalf("Node %s is still advertised as operational after unpairing", nodeID)
+}
@@ -0,0 +1,59 @@
+package utils
+
+import (
+	"fmt"
+	"slices"
+	"testing"
+	"time"
+)
+
+// CHIP error of operations that got no response
+const chipErrorTimeout = 0x32
+
+// BlockUDPPort drops incoming IPv4 and IPv6 packets to a local UDP port,
+// e.g. the operational port of a device, until test cleanup.
+// The test is skipped if iptables can't be used.
+func BlockUDPPort(t *testing.T, port string) {
+	for _, tool := range []string{"iptables", "ip6tables"} {
+		if _, _, err := Exec(nil, fmt.Sprintf("sudo %s --list --numeric", tool)); err != nil {
+			t.Skipf("Can't use %s: %s", tool, err)
+		}
+	}
+
+	for _, tool := range []string{"iptables", "ip6tables"} {
+		rule := fmt.Sprintf(
+			"INPUT --protocol udp --dport %s --match comment --comment matter-snap-testing --jump DROP",
+			port,
+		)
+		if _, stderr, err := ExecVerbose(t, fmt.Sprintf("sudo %s --insert %s", tool, rule)); err != nil {
+			t.Fatalf("Error blocking port %s: %s: %s", port, err, stderr)
+		}
+		t.Cleanup(func() {
+			ExecVerbose(t, fmt.Sprintf("sudo %s --delete %s", tool, rule))
+		})
+	}
+}
+
+// RequireTimeoutUnderPartition blocks a device's operational UDP port and
+// checks that reading an attribute fails with a CHIP timeout error within the
+// given duration, instead of hanging
+func RequireTimeoutUnderPartition(t *testing.T, nodeID, port string, maxDuration time.Duration) {
+	BlockUDPPort(t, port)
+
+	start := time.Now()
+	stdout, stderr, err := ExecVerbose(nil, chipToolTimeoutCommand(maxDuration,
+		readAttributeCommand(nodeID, "basicinformation", "vendor-id", "0")))
+	elapsed := time.Since(start)
+
+	switch {
+	case err == nil:
+		t.Fatalf("Read succeeded despite blocked port %s", port)
+	case ExitCode(err) == timeoutExitCode:
+		t.Fatalf("Time out: read hung for %s with blocked port %s", maxDuration, port)
+	}
+
+	if !slices.Contains(parseChipErrors(stdout+stderr), chipErrorTimeout) {
+		t.Fatalf("Read failed without CHIP timeout error 0x%08X: %s", chipErrorTimeout, err)
+	}
+	t.Logf("Read timed out after %s with blocked port %s", elapsed.Round(time.Millisecond), port)
+}
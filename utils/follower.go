@@ -0,0 +1,152 @@
+package utils
+
+import (
+	"bufio"
+	"fmt"
+	goexec "os/exec"
+	"strings"
+	"sync"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// LogFollower collects the journal of a snap in the background, as it arrives
+type LogFollower struct {
+	snap string
+	cmd  *goexec.Cmd
+
+	mutex sync.Mutex
+	lines []string
+	// arrival times of the lines
+	times  []time.Time
+	update chan struct{}
+	done   chan struct{}
+}
+
+// FollowSnapLogs starts following the journal of a snap from now on.
+// The follower is stopped on test cleanup.
+func FollowSnapLogs(t *testing.T, snap string) *LogFollower {
+	t.Helper()
+
+	command := fmt.Sprintf("sudo journalctl --follow --no-pager --since \"%s\"",
+		time.Now().Format("2006-01-02 15:04:05"))
+	t.Logf("[exec] %s", command)
+
+	f := &LogFollower{
+		snap:   snap,
+		cmd:    goexec.Command("/bin/bash", "-c", command),
+		update: make(chan struct{}, 1),
+		done:   make(chan struct{}),
+	}
+	stdout, err := f.cmd.StdoutPipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = startProcess(t, f.cmd); err != nil {
+		t.Fatal(err)
+	}
+
+	go func() {
+		defer close(f.done)
+
+		scanner := bufio.NewScanner(stdout)
+		for scanner.Scan() {
+			f.add(scanner.Text())
+		}
+		waitProcess(f.cmd)
+	}()
+
+	t.Cleanup(f.Stop)
+	return f
+}
+
+// add collects the line if it belongs to the snap, using the same filter as SnapLogs
+func (f *LogFollower) add(line string) {
+	if !strings.Contains(line, f.snap) {
+		return
+	}
+	if _, key := SplitSnapInstanceName(f.snap); key == "" && strings.Contains(line, f.snap+"_") {
+		return
+	}
+
+	f.mutex.Lock()
+	f.lines = append(f.lines, line)
+	f.times = append(f.times, time.Now())
+	f.mutex.Unlock()
+
+	select {
+	case f.update <- struct{}{}:
+	default:
+	}
+}
+
+// Lines returns the lines collected so far
+func (f *LogFollower) Lines() []string {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	return append([]string(nil), f.lines...)
+}
+
+// ArrivalTime returns when the follower received the line with the index,
+// e.g. as returned by WaitForFrom
+func (f *LogFollower) ArrivalTime(index int) time.Time {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	return f.times[index]
+}
+
+// WaitFor waits until a collected line contains the expected content and
+// returns that line. Lines collected before the call are also considered.
+func (f *LogFollower) WaitFor(t *testing.T, expected string, timeout time.Duration) string {
+	t.Helper()
+	line, _ := f.WaitForFrom(t, expected, 0, timeout)
+	return line
+}
+
+// WaitForFrom is like WaitFor, but only considers the lines from the given
+// index on, e.g. the number of lines collected before an action. It also
+// returns the index of the line.
+func (f *LogFollower) WaitForFrom(t *testing.T, expected string, from int, timeout time.Duration) (string, int) {
+	t.Helper()
+	t.Logf("Waiting for expected content in logs: %s", expected)
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+
+	for checked := from; ; {
+		lines := f.Lines()
+		for i := checked; i < len(lines); i++ {
+			if strings.Contains(lines[i], expected) {
+				t.Logf("Found expected content in logs: %s", expected)
+				return lines[i], i
+			}
+		}
+		checked = max(checked, len(lines))
+
+		select {
+		case <-f.update:
+		case <-f.done:
+			t.Fatalf("Log follower exited while waiting for: %s", expected)
+		case <-timer.C:
+			t.Fatalf("Time out: waited %s for expected content in logs: %s", timeout, expected)
+		}
+	}
+}
+
+// Stop stops following the journal
+func (f *LogFollower) Stop() {
+	select {
+	case <-f.done:
+		return
+	default:
+	}
+
+	syscall.Kill(-f.cmd.Process.Pid, syscall.SIGTERM)
+	select {
+	case <-f.done:
+	case <-time.After(5 * time.Second):
+		syscall.Kill(-f.cmd.Process.Pid, syscall.SIGKILL)
+		<-f.done
+	}
+}
@@ -0,0 +1,30 @@
+package utils
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLogFollowerArrivalTime(t *testing.T) {
+	f := &LogFollower{
+		snap:   "matter-all-clusters-app",
+		update: make(chan struct{}, 1),
+		done:   make(chan struct{}),
+	}
+
+	start := time.Now()
+	f.add("matter-all-clusters-app.all-clusters-app[123]: Toggle ep1 on/off from state 0 to 1")
+	time.Sleep(10 * time.Millisecond)
+	f.add("matter-all-clusters-app_rev2.all-clusters-app[124]: Toggle ep1 on/off from state 0 to 1")
+	f.add("matter-all-clusters-app.all-clusters-app[123]: Toggle ep1 on/off from state 1 to 0")
+
+	line, index := f.WaitForFrom(t, "Toggle ep1", 1, time.Second)
+	assert.Equal(t, 1, index, "line of the parallel instance is skipped")
+	assert.Contains(t, line, "from state 1 to 0")
+
+	first, second := f.ArrivalTime(0), f.ArrivalTime(index)
+	assert.False(t, first.Before(start))
+	assert.GreaterOrEqual(t, second.Sub(first), 10*time.Millisecond)
+}
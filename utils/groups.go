@@ -0,0 +1,27 @@
+package utils
+
+import (
+	"fmt"
+	"testing"
+)
+
+// ChipToolReadGroups returns the IDs of the groups an endpoint is a member of
+func ChipToolReadGroups(t *testing.T, nodeID, endpoint string) []string {
+	stdout, _, _ := ChipTool(t, fmt.Sprintf(
+		"groups get-group-membership '[]' %s %s",
+		nodeID,
+		endpoint,
+	))
+	return parseNamedList(stdout, "groupList")
+}
+
+// ChipToolReadScenes returns the IDs of the scenes of a group on an endpoint
+func ChipToolReadScenes(t *testing.T, nodeID, endpoint, groupID string) []string {
+	stdout, _, _ := ChipTool(t, fmt.Sprintf(
+		"scenesmanagement get-scene-membership %s %s %s",
+		groupID,
+		nodeID,
+		endpoint,
+	))
+	return parseNamedList(stdout, "sceneList")
+}
@@ -0,0 +1,37 @@
+package utils
+
+import (
+	"os"
+	"strings"
+	"testing"
+)
+
+// RequireBluetoothAdapter skips the test if the machine has no Bluetooth
+// adapter, e.g. on CI runners, so that BLE tests run only on hardware
+func RequireBluetoothAdapter(t *testing.T) {
+	if len(sysClassEntries("bluetooth", "hci")) == 0 {
+		t.Skip("No Bluetooth adapter found in /sys/class/bluetooth")
+	}
+}
+
+// RequireThreadInterface skips the test if the machine has no Thread network
+// interface, such as wpan0 of an OpenThread Border Router with a radio
+func RequireThreadInterface(t *testing.T) {
+	if len(sysClassEntries("net", "wpan")) == 0 {
+		t.Skip("No Thread network interface (wpan*) found in /sys/class/net")
+	}
+}
+
+// sysClassEntries returns the devices of a /sys/class directory with the prefix
+func sysClassEntries(class, prefix string) (names []string) {
+	entries, err := os.ReadDir("/sys/class/" + class)
+	if err != nil {
+		return nil
+	}
+	for _, e := range entries {
+		if strings.HasPrefix(e.Name(), prefix) {
+			names = append(names, e.Name())
+		}
+	}
+	return names
+}
@@ -0,0 +1,73 @@
+package utils
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+// interfaces for desktop sessions, which aren't available on headless devices
+var desktopInterfaces = []string{
+	"desktop",
+	"desktop-launch",
+	"desktop-legacy",
+	"gsettings",
+	"screen-inhibit-control",
+	"unity7",
+	"wayland",
+	"x11",
+}
+
+// e.g. audit: type=1400 audit(1712236307.960:123): apparmor="DENIED"
+// operation="dbus_method_call" bus="session" path="/org/freedesktop/Notifications"
+// ... label="snap.foo.bar"
+var appArmorDenialExp = regexp.MustCompile(`apparmor="DENIED".*?(?:profile|label)="(snap\.[^"]+)"`)
+
+// resources of desktop sessions in AppArmor denials
+var desktopResourceExp = regexp.MustCompile(
+	`bus="session"|org\.freedesktop\.Notifications|\.X11-unix|wayland-\d|/dconf/|/run/user/\d+/`)
+
+// parseDesktopDenials returns the AppArmor denials of a snap's apps in the
+// kernel log which concern desktop session resources
+func parseDesktopDenials(logs, snap string) (denials []string) {
+	for _, line := range strings.Split(logs, "\n") {
+		match := appArmorDenialExp.FindStringSubmatch(line)
+		if match == nil || !strings.HasPrefix(match[1], "snap."+snap+".") {
+			continue
+		}
+		if desktopResourceExp.MatchString(line) {
+			denials = append(denials, strings.TrimSpace(line))
+		}
+	}
+	return denials
+}
+
+// RequireHeadlessClean checks that a snap neither declares plugs of desktop
+// interfaces nor tries to use desktop session resources, e.g. notifications
+// via the session bus, while running the operation
+func RequireHeadlessClean(t *testing.T, snap string, operation func()) {
+	for _, c := range SnapConnections(t, snap) {
+		plugSnap, _, _ := strings.Cut(c.Plug, ":")
+		if plugSnap == snap && slices.Contains(desktopInterfaces, c.Interface) {
+			t.Errorf("Snap %s has plug %s of desktop interface %s", snap, c.Plug, c.Interface)
+		}
+	}
+
+	start := time.Now()
+	operation()
+
+	logs, _, _ := Exec(t, fmt.Sprintf(
+		"sudo journalctl --dmesg --no-pager --since \"%s\" --grep 'apparmor=\"DENIED\"' || true",
+		start.Format("2006-01-02 15:04:05"),
+	))
+	for _, d := range parseDesktopDenials(logs, snap) {
+		t.Errorf("Snap %s was denied a desktop resource: %s", snap, d)
+	}
+
+	if t.Failed() {
+		t.FailNow()
+	}
+}
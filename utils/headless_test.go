@@ -0,0 +1,18 @@
+package utils
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseDesktopDenials(t *testing.T) {
+	denials := parseDesktopDenials(`
+Apr 04 10:00:00 host kernel: audit: type=1400 audit(1712236307.960:123): apparmor="DENIED" operation="dbus_method_call" bus="session" path="/org/freedesktop/Notifications" interface="org.freedesktop.Notifications" member="Notify" mask="send" name="org.freedesktop.Notifications" pid=1234 label="snap.matter-all-clusters-app.all-clusters-app" peer_pid=900 peer_label="unconfined"
+Apr 04 10:00:01 host kernel: audit: type=1400 audit(1712236308.960:124): apparmor="DENIED" operation="open" profile="snap.matter-all-clusters-app.all-clusters-app" name="/dev/gpiochip0" pid=1234 comm="chip-all-cluste" requested_mask="wr" denied_mask="wr" fsuid=0 ouid=0
+Apr 04 10:00:02 host kernel: audit: type=1400 audit(1712236309.960:125): apparmor="DENIED" operation="connect" profile="snap.chip-tool.chip-tool" name="/tmp/.X11-unix/X0" pid=1235 comm="chip-tool" requested_mask="wr" denied_mask="wr" fsuid=0 ouid=0
+`, "matter-all-clusters-app")
+	require.Len(t, denials, 1)
+	assert.Contains(t, denials[0], "org.freedesktop.Notifications")
+}
@@ -0,0 +1,64 @@
+package utils
+
+import (
+	"encoding/json"
+	"fmt"
+	"testing"
+)
+
+// SnapHealthResult is the health of a snap as reported by its check-health
+// hook or `snapctl set-health`
+type SnapHealthResult struct {
+	Status  string `json:"status"` // okay, waiting, blocked or error, empty if not reported
+	Message string `json:"message"`
+	Code    string `json:"code"`
+}
+
+// SnapHealth returns the health of a snap from the snapd API, which `snap
+// info` doesn't show
+func SnapHealth(t *testing.T, name string) SnapHealthResult {
+	out, _, _ := Exec(t, fmt.Sprintf(
+		"sudo curl --silent --unix-socket /run/snapd.socket http://localhost/v2/snaps/%s",
+		name,
+	))
+	health, err := parseSnapHealth(out)
+	if err != nil {
+		t.Fatalf("Error reading health of %s: %s", name, err)
+	}
+	return health
+}
+
+func parseSnapHealth(response string) (SnapHealthResult, error) {
+	var body struct {
+		Result struct {
+			Health  *SnapHealthResult `json:"health"`
+			Message string            `json:"message"`
+		} `json:"result"`
+		Type string `json:"type"`
+	}
+	if err := json.Unmarshal([]byte(response), &body); err != nil {
+		return SnapHealthResult{}, err
+	}
+	if body.Type == "error" {
+		return SnapHealthResult{}, fmt.Errorf("%s", body.Result.Message)
+	}
+	if body.Result.Health == nil {
+		return SnapHealthResult{}, nil
+	}
+	return *body.Result.Health, nil
+}
+
+// RequireSnapHealthy checks that a snap which reports its health is okay,
+// surfacing failures of its check-health hook
+func RequireSnapHealthy(t *testing.T, name string) {
+	health := SnapHealth(t, name)
+	switch health.Status {
+	case "":
+		t.Logf("Snap %s reports no health", name)
+	case "okay":
+		t.Logf("Snap %s is healthy", name)
+	default:
+		t.Fatalf("Snap %s has health status %s: %s (code %s)",
+			name, health.Status, health.Message, health.Code)
+	}
+}
@@ -0,0 +1,20 @@
+package utils
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseSnapHealth(t *testing.T) {
+	health, err := parseSnapHealth(`{"type":"sync","status-code":200,"status":"OK","result":{"name":"matter-all-clusters-app","health":{"revision":"12","timestamp":"2024-04-04T10:00:00Z","status":"blocked","message":"thread interface missing","code":"no-thread"}}}`)
+	assert.NoError(t, err)
+	assert.Equal(t, SnapHealthResult{Status: "blocked", Message: "thread interface missing", Code: "no-thread"}, health)
+
+	health, err = parseSnapHealth(`{"type":"sync","status-code":200,"status":"OK","result":{"name":"chip-tool"}}`)
+	assert.NoError(t, err)
+	assert.Empty(t, health.Status)
+
+	_, err = parseSnapHealth(`{"type":"error","status-code":404,"status":"Not Found","result":{"message":"snap not installed","kind":"snap-not-found"}}`)
+	assert.EqualError(t, err, "snap not installed")
+}
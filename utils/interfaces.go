@@ -0,0 +1,278 @@
+package utils
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+// RequireContentConnected checks that the content plug of one snap is
+// connected to the content slot of another snap and that the content is
+// shared at the given path, e.g. /snap/<plug snap>/current/<target>
+func RequireContentConnected(t *testing.T, plugSnap, plug, slotSnap, slot, sharedPath string) {
+	plugName := plugSnap + ":" + plug
+	slotName := slotSnap + ":" + slot
+
+	var found bool
+	for _, c := range SnapConnections(t, plugSnap) {
+		if c.Plug == plugName &&
+			strings.HasPrefix(c.Interface, "content") &&
+			c.SlotSnap() == slotSnap &&
+			c.Slot == slotName {
+			found = true
+			break
+		}
+	}
+	if !found {
+		t.Fatalf("Content plug %s is not connected to slot %s", plugName, slotName)
+	}
+
+	// The command should not return error even if the directory is empty, hence the "|| true"
+	stdout, _, _ := Exec(t, fmt.Sprintf("sudo ls -A %s || true", sharedPath))
+	if strings.TrimSpace(stdout) == "" {
+		t.Fatalf("Shared content path %s is empty or missing", sharedPath)
+	}
+	t.Logf("Content plug %s is connected to %s and shares %s", plugName, slotName, sharedPath)
+}
+
+// SnapDeclaredPlugs returns the sorted names of all plugs declared by a snap,
+// connected or not
+func SnapDeclaredPlugs(t *testing.T, snap string) []string {
+	// the connections of a single snap include the disconnected plugs
+	// and slots, same as --all for all snaps
+	return declaredPlugs(snap, SnapConnections(t, snap))
+}
+
+func declaredPlugs(snap string, connections []SnapConnection) (plugs []string) {
+	for _, c := range connections {
+		plugSnap, plug, _ := strings.Cut(c.Plug, ":")
+		if plugSnap == snap && !slices.Contains(plugs, plug) {
+			plugs = append(plugs, plug)
+		}
+	}
+	slices.Sort(plugs)
+	return plugs
+}
+
+// RequirePlugSet checks that the snap declares exactly the expected plugs
+func RequirePlugSet(t *testing.T, snap string, expected []string) {
+	plugs := SnapDeclaredPlugs(t, snap)
+
+	for _, p := range plugs {
+		if !slices.Contains(expected, p) {
+			t.Errorf("Snap %s declares unexpected plug: %s", snap, p)
+		}
+	}
+	for _, p := range expected {
+		if !slices.Contains(plugs, p) {
+			t.Errorf("Snap %s does not declare expected plug: %s", snap, p)
+		}
+	}
+	if t.Failed() {
+		t.FailNow()
+	}
+}
+
+// normalizeSlot returns the slot as printed by `snap connections`, where
+// system slots have no snap name, e.g. :network instead of snapd:network
+func normalizeSlot(slot string) string {
+	snap, name, found := strings.Cut(slot, ":")
+	if !found {
+		return ":" + slot
+	}
+	switch snap {
+	case "core", "snapd", "system":
+		return ":" + name
+	}
+	return slot
+}
+
+// RequireConnected checks that a plug, <snap>:<plug>, is connected to a slot,
+// e.g. <snap>:serial connected to the pi gadget's pi:serial-port slot
+func RequireConnected(t *testing.T, plug, slot string) {
+	plugSnap, _, _ := strings.Cut(plug, ":")
+	slot = normalizeSlot(slot)
+
+	var connectedSlots []string
+	for _, c := range SnapConnections(t, plugSnap) {
+		if c.Plug != plug || !c.Connected() {
+			continue
+		}
+		if c.Slot == slot {
+			t.Logf("Plug %s is connected to %s (%s)", plug, slot, c.Notes)
+			return
+		}
+		connectedSlots = append(connectedSlots, c.Slot)
+	}
+
+	if len(connectedSlots) > 0 {
+		t.Fatalf("Plug %s is connected to %s instead of %s",
+			plug, strings.Join(connectedSlots, ", "), slot)
+	}
+	t.Fatalf("Plug %s is not connected", plug)
+}
+
+// RequireSlotPresent checks that a snap exposes a slot, whether or not any
+// plug is connected to it
+func RequireSlotPresent(t *testing.T, snap, slot string) {
+	slotName := snap + ":" + slot
+	for _, c := range SnapConnections(t, snap) {
+		if c.Slot == slotName {
+			t.Logf("Snap %s exposes slot %s (%s)", snap, slot, c.Interface)
+			return
+		}
+	}
+	t.Fatalf("Snap %s does not expose slot %s", snap, slot)
+}
+
+// SlotProviderSystem is the provider of system slots for RequireSlotProvider
+const SlotProviderSystem = "system"
+
+// RequireSlotProvider checks which provides the slot that a snap's plug is
+// connected to: the system (SlotProviderSystem) or the named snap, e.g. the
+// bluez snap on images where BlueZ isn't part of the base system
+func RequireSlotProvider(t *testing.T, snap, plug, provider string) {
+	plugName := snap + ":" + plug
+
+	var providers []string
+	for _, c := range SnapConnections(t, snap) {
+		if c.Plug != plugName || !c.Connected() {
+			continue
+		}
+		p := c.SlotSnap()
+		if c.SystemSlot() {
+			p = SlotProviderSystem
+		}
+		if p == provider {
+			t.Logf("Plug %s is connected to %s, provided by %s", plugName, c.Slot, provider)
+			return
+		}
+		providers = append(providers, p)
+	}
+
+	if len(providers) == 0 {
+		t.Fatalf("Plug %s is not connected", plugName)
+	}
+	t.Fatalf("Plug %s is connected to slots provided by %s instead of %s",
+		plugName, strings.Join(providers, ", "), provider)
+}
+
+// e.g. audit: type=1400 audit(1712236307.960:123): apparmor="DENIED"
+// operation="create" class="net" profile="snap.foo.bar" pid=1234 comm="bar"
+// family="inet6" sock_type="dgram" protocol=0 requested_mask="create"
+var networkDenialExp = regexp.MustCompile(`family="inet6?"`)
+
+// parseNetworkDenials returns the AppArmor denials of a snap's apps in the
+// kernel log which concern internet sockets
+func parseNetworkDenials(logs, snap string) (denials []string) {
+	for _, line := range strings.Split(logs, "\n") {
+		match := appArmorDenialExp.FindStringSubmatch(line)
+		if match == nil || !strings.HasPrefix(match[1], "snap."+snap+".") {
+			continue
+		}
+		if networkDenialExp.MatchString(line) {
+			denials = append(denials, strings.TrimSpace(line))
+		}
+	}
+	return denials
+}
+
+// RequireNetworkBindRequired checks end to end that a snap needs its
+// network-bind plug to open a TCP or UDP port: with the plug disconnected,
+// the restarted services must not open the port and the kernel must log a
+// denial. For TCP, that is a seccomp denial of a call allowed by
+// network-bind, e.g. listen. Binding a UDP port needs no such call and the
+// network interface also allows UDP sockets, so for UDP the network plug is
+// disconnected as well and an AppArmor denial of an internet socket is
+// expected instead. After reconnecting the plugs, the restarted services
+// must open the port for the protocol. The plugs are restored to their
+// previous state on cleanup.
+func RequireNetworkBindRequired(t *testing.T, snap, port, protocol string) {
+	const iface = "network-bind"
+	plug := snap + ":" + iface
+
+	protocol = strings.ToLower(protocol)
+	if protocol != "tcp" && protocol != "udp" {
+		t.Fatalf("Unsupported protocol %s, expected tcp or udp", protocol)
+	}
+
+	connections := SnapConnections(t, snap)
+	if !slices.ContainsFunc(connections, func(c SnapConnection) bool {
+		return c.Plug == plug && c.Interface == iface
+	}) {
+		t.Fatalf("Snap %s has no %s plug", snap, iface)
+	}
+
+	plugs := []string{plug}
+	if protocol == "udp" && slices.ContainsFunc(connections, func(c SnapConnection) bool {
+		return c.Plug == snap+":network"
+	}) {
+		plugs = append(plugs, snap+":network")
+	}
+	connected := make(map[string]bool)
+	for _, c := range connections {
+		if slices.Contains(plugs, c.Plug) && c.Connected() {
+			connected[c.Plug] = true
+		}
+	}
+	t.Cleanup(func() {
+		for _, p := range plugs {
+			if connected[p] {
+				SnapConnect(nil, p, "")
+			} else {
+				SnapDisconnect(nil, p, "")
+			}
+		}
+		SnapStart(t, snap)
+	})
+
+	SnapStop(t, snap)
+	for _, p := range plugs {
+		SnapDisconnect(t, p, "")
+	}
+	disconnected := strings.Join(plugs, " and ")
+
+	start := time.Now()
+	denials := SnapSeccompDenials(t, snap, func() {
+		SnapStart(t, snap)
+		RequirePortNotOpen(t, port, 15*time.Second)
+	})
+	if protocol == "tcp" {
+		if !slices.ContainsFunc(denials, func(d SeccompDenial) bool { return d.Interface == iface }) {
+			t.Fatalf("Found no seccomp denial of %s calls with %s disconnected, denials: %v", iface, plug, denials)
+		}
+	} else {
+		logs, _, _ := Exec(t, fmt.Sprintf(
+			"sudo journalctl --dmesg --no-pager --since \"%s\" --grep 'apparmor=\"DENIED\"' || true",
+			start.Format("2006-01-02 15:04:05"),
+		))
+		if len(parseNetworkDenials(logs, snap)) == 0 {
+			t.Fatalf("Found no AppArmor denial of internet sockets with %s disconnected", disconnected)
+		}
+	}
+	t.Logf("Port %s/%s stayed closed and was denied with %s disconnected", port, protocol, disconnected)
+
+	SnapStop(t, snap)
+	for _, p := range plugs {
+		if err := SnapConnect(t, p, ""); err != nil {
+			t.Fatalf("Error connecting %s: %s", p, err)
+		}
+	}
+	SnapStart(t, snap)
+
+	const maxRetry = 60
+	for i := 1; i <= maxRetry; i++ {
+		t.Logf("Retry %d/%d: Waiting for port: %s/%s", i, maxRetry, port, protocol)
+		for _, l := range Listeners(t) {
+			if l.Port == port && strings.ToLower(l.Protocol) == protocol {
+				t.Logf("Port %s is open by %s (%s)", l.key(), l.Command, l.PID)
+				return
+			}
+		}
+		time.Sleep(1 * time.Second)
+	}
+	t.Fatalf("Time out: reached max %d retries.", maxRetry)
+}
@@ -0,0 +1,38 @@
+package utils
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDeclaredPlugs(t *testing.T) {
+	plugs := declaredPlugs("chip-tool", parseSnapConnections(`
+Interface      Plug                     Slot                   Notes
+bluez          chip-tool:bluez          :bluez                 -
+bluez          chip-tool:bluez          bluez:service          manual
+network        chip-tool:network        :network               -
+avahi-observe  chip-tool:avahi-observe  -                      -
+content        other:content            chip-tool:content      -
+`))
+	assert.Equal(t, []string{"avahi-observe", "bluez", "network"}, plugs)
+}
+
+func TestNormalizeSlot(t *testing.T) {
+	assert.Equal(t, ":network", normalizeSlot("network"))
+	assert.Equal(t, ":network", normalizeSlot(":network"))
+	assert.Equal(t, ":bluez", normalizeSlot("snapd:bluez"))
+	assert.Equal(t, "pi:serial-port", normalizeSlot("pi:serial-port"))
+}
+
+func TestParseNetworkDenials(t *testing.T) {
+	denials := parseNetworkDenials(`
+Apr 04 10:00:00 host kernel: audit: type=1400 audit(1712236307.960:123): apparmor="DENIED" operation="create" class="net" profile="snap.matter-all-clusters-app.all-clusters-app" pid=1234 comm="chip-all-cluste" family="inet6" sock_type="dgram" protocol=0 requested_mask="create" denied_mask="create"
+Apr 04 10:00:01 host kernel: audit: type=1400 audit(1712236308.960:124): apparmor="DENIED" operation="create" class="net" profile="snap.matter-all-clusters-app.all-clusters-app" pid=1234 comm="chip-all-cluste" family="netlink" sock_type="raw" protocol=0 requested_mask="create" denied_mask="create"
+Apr 04 10:00:02 host kernel: audit: type=1400 audit(1712236309.960:125): apparmor="DENIED" operation="create" class="net" profile="snap.chip-tool.chip-tool" pid=1235 comm="chip-tool" family="inet" sock_type="dgram" protocol=0 requested_mask="create" denied_mask="create"
+`, "matter-all-clusters-app")
+
+	require.Len(t, denials, 1)
+	assert.Contains(t, denials[0], `family="inet6"`)
+}
@@ -0,0 +1,102 @@
+package utils
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+// LatencyStats summarizes a series of latency measurements
+type LatencyStats struct {
+	Samples int
+	Min     time.Duration
+	Max     time.Duration
+	Avg     time.Duration
+}
+
+func newLatencyStats(latencies []time.Duration) LatencyStats {
+	if len(latencies) == 0 {
+		return LatencyStats{}
+	}
+
+	stats := LatencyStats{
+		Samples: len(latencies),
+		Min:     latencies[0],
+		Max:     latencies[0],
+	}
+	var sum time.Duration
+	for _, l := range latencies {
+		stats.Min = min(stats.Min, l)
+		stats.Max = max(stats.Max, l)
+		sum += l
+	}
+	stats.Avg = sum / time.Duration(len(latencies))
+	return stats
+}
+
+func (s LatencyStats) String() string {
+	return fmt.Sprintf("samples=%d min=%s max=%s avg=%s", s.Samples, s.Min, s.Max, s.Avg)
+}
+
+// MeasureReadLatency reads an attribute of a commissioned device n times
+// using an interactive chip-tool session and returns the wall-clock latencies
+func MeasureReadLatency(t *testing.T, nodeID, cluster, attribute, endpoint string, n int) LatencyStats {
+	session := StartChipToolSession(t)
+
+	latencies := make([]time.Duration, 0, n)
+	for i := 1; i <= n; i++ {
+		start := time.Now()
+		if _, err := session.ReadAttribute(t, nodeID, cluster, attribute, endpoint); err != nil {
+			t.Fatalf("Read %d/%d of %s %s failed: %s", i, n, cluster, attribute, err)
+		}
+		latencies = append(latencies, time.Since(start))
+	}
+
+	stats := newLatencyStats(latencies)
+	t.Logf("Read latency of %s %s: %s", cluster, attribute, stats)
+	return stats
+}
+
+// RequireReadLatencyUnder checks that the average read latency is below the threshold
+func RequireReadLatencyUnder(t *testing.T, stats LatencyStats, threshold time.Duration) {
+	if stats.Avg >= threshold {
+		t.Fatalf("Average read latency %s is not under %s (%s)", stats.Avg, threshold, stats)
+	}
+}
+
+// MeasureControlLatency toggles the OnOff cluster of a commissioned device n
+// times and returns the latencies from sending each command until the device
+// snap logs the actuation, e.g. "Toggle ep1 on/off". Unlike read latency, this
+// includes the processing in the device app, but not the response to
+// chip-tool. The device logs are collected as they arrive with a LogFollower
+// and the latency is taken from the arrival time of the matching line, which
+// includes the delivery by journald, usually in the order of milliseconds.
+func MeasureControlLatency(t *testing.T, snap, nodeID, endpoint, expectedLog string, n int) LatencyStats {
+	const timeout = 30 * time.Second
+
+	follower := FollowSnapLogs(t, snap)
+	session := StartChipToolSession(t)
+
+	latencies := make([]time.Duration, 0, n)
+	for i := 1; i <= n; i++ {
+		from := len(follower.Lines())
+		start := time.Now()
+		if _, err := session.Run(t, fmt.Sprintf("onoff toggle %s %s", nodeID, endpoint), timeout); err != nil {
+			t.Fatalf("Toggle %d/%d failed: %s", i, n, err)
+		}
+		_, index := follower.WaitForFrom(t, expectedLog, from, timeout)
+		latencies = append(latencies, follower.ArrivalTime(index).Sub(start))
+	}
+
+	stats := newLatencyStats(latencies)
+	t.Logf("Control latency of onoff toggle: %s", stats)
+	return stats
+}
+
+// RequireControlLatencyUnder checks that the average control latency is
+// below the threshold
+func RequireControlLatencyUnder(t *testing.T, stats LatencyStats, threshold time.Duration) {
+	if stats.Avg >= threshold {
+		t.Fatalf("Average control latency %s is not under %s (%s)", stats.Avg, threshold, stats)
+	}
+}
@@ -0,0 +1,118 @@
+package utils
+
+import (
+	"fmt"
+	"os"
+	"runtime"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// LeakCheck holds the resource usage of the harness process at a point in time
+type LeakCheck struct {
+	fds        int
+	goroutines int
+}
+
+// StartLeakCheck records the number of open file descriptors and goroutines
+// of the test process. It is meant to be called from TestMain before m.Run()
+func StartLeakCheck() (*LeakCheck, error) {
+	fds, err := openFDs()
+	if err != nil {
+		return nil, err
+	}
+	return &LeakCheck{
+		fds:        fds,
+		goroutines: runtime.NumGoroutine(),
+	}, nil
+}
+
+// Check compares the current number of open file descriptors and goroutines
+// with the recorded ones and returns an error if either grew beyond threshold
+func (l *LeakCheck) Check(threshold int) error {
+	var fds, goroutines int
+	var err error
+
+	// give exiting goroutines and reaped child processes a moment to release
+	// their resources
+	const maxRetry = 10
+	for i := 1; i <= maxRetry; i++ {
+		fds, err = openFDs()
+		if err != nil {
+			return err
+		}
+		goroutines = runtime.NumGoroutine()
+
+		if fds-l.fds <= threshold && goroutines-l.goroutines <= threshold {
+			return nil
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+
+	return fmt.Errorf("Harness leak: file descriptors %d -> %d, goroutines %d -> %d (threshold %d)",
+		l.fds, fds, l.goroutines, goroutines, threshold)
+}
+
+// openFDs returns the number of file descriptors open by the current process
+func openFDs() (int, error) {
+	entries, err := os.ReadDir("/proc/self/fd")
+	if err != nil {
+		return 0, fmt.Errorf("Can't read open file descriptors: %s", err)
+	}
+	return len(entries), nil
+}
+
+// SnapServiceFDs returns the number of file descriptors open by the
+// processes of a snap service
+func SnapServiceFDs(t *testing.T, snap, service string) int {
+	// the cgroup of the service, snap.<snap>.<service>.service, matches the
+	// snap name filter of SnapPIDs
+	pids := SnapPIDs(t, snap+"."+service)
+	if len(pids) == 0 {
+		t.Fatalf("Service %s has no running processes", SnapServiceUnit(snap, service))
+	}
+
+	var fds int
+	for _, pid := range pids {
+		out, _, _ := Exec(t, fmt.Sprintf("sudo ls /proc/%s/fd | wc -l", pid))
+		n, err := strconv.Atoi(strings.TrimSpace(out))
+		if err != nil {
+			t.Fatalf("Invalid number of file descriptors of %s: %s", pid, err)
+		}
+		fds += n
+	}
+	return fds
+}
+
+// RequireNoFDLeak commissions and decommissions the device of a snap service
+// and checks that the service's number of open file descriptors returns to
+// the baseline afterwards
+func RequireNoFDLeak(t *testing.T, snap, service string) {
+	const nodeID = "110"
+
+	baseline := SnapServiceFDs(t, snap, service)
+	t.Logf("Service %s has %d open file descriptors", SnapServiceUnit(snap, service), baseline)
+
+	if err := ChipToolPairOnNetwork(t, nodeID, DefaultSetupPINCode); err != nil {
+		t.Fatalf("Error commissioning: %s", err)
+	}
+	if err := ChipToolUnpair(t, nodeID); err != nil {
+		t.Fatalf("Error decommissioning: %s", err)
+	}
+	ChipToolReset(t)
+
+	// give the device a moment to close its sessions
+	var fds int
+	const maxRetry = 10
+	for i := 1; i <= maxRetry; i++ {
+		if fds = SnapServiceFDs(t, snap, service); fds <= baseline {
+			t.Logf("Service %s is back to %d open file descriptors", SnapServiceUnit(snap, service), fds)
+			return
+		}
+		time.Sleep(1 * time.Second)
+	}
+	t.Fatalf("Service %s leaked file descriptors over a commissioning cycle: %d -> %d",
+		SnapServiceUnit(snap, service), baseline, fds)
+}
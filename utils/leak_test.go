@@ -0,0 +1,35 @@
+package utils
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestLeakCheck(t *testing.T) {
+
+	t.Run("exec does not leak", func(t *testing.T) {
+		leakCheck, err := StartLeakCheck()
+		require.NoError(t, err)
+
+		for i := 0; i < 20; i++ {
+			_, _, err := exec(nil, nil, `echo "hi"`, false)
+			require.NoError(t, err)
+		}
+
+		require.NoError(t, leakCheck.Check(0))
+	})
+
+	t.Run("detect leak", func(t *testing.T) {
+		leakCheck, err := StartLeakCheck()
+		require.NoError(t, err)
+
+		stop := make(chan struct{})
+		t.Cleanup(func() { close(stop) })
+		for i := 0; i < 5; i++ {
+			go func() { <-stop }()
+		}
+
+		require.Error(t, leakCheck.Check(2))
+	})
+}
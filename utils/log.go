@@ -1,38 +1,138 @@
 package utils
 
 import (
+	"fmt"
+	"hash/fnv"
+	"log"
 	"os"
+	"path/filepath"
+	"regexp"
 	"strings"
+	"sync"
 	"testing"
 	"time"
+	"unicode/utf8"
 )
 
+// directory of the log files, relative to the working directory
+var logDirectory = "logs"
+
+// temporary log directory used if logDirectory isn't writable
+var fallbackLogDirectory struct {
+	once sync.Once
+	dir  string
+	err  error
+}
+
+// logDir returns the log directory, creating it if needed. If it isn't
+// writable, e.g. on a read-only file system, a temporary directory is used.
+func logDir() (string, error) {
+	if err := os.MkdirAll(logDirectory, 0777); err == nil {
+		if f, err := os.CreateTemp(logDirectory, ".write-test-"); err == nil {
+			f.Close()
+			os.Remove(f.Name())
+			return logDirectory, nil
+		}
+	}
+
+	fallbackLogDirectory.once.Do(func() {
+		fallbackLogDirectory.dir, fallbackLogDirectory.err = os.MkdirTemp("", "matter-snap-testing-logs-")
+		if fallbackLogDirectory.err == nil {
+			log.Printf("Warning: log directory %s is not writable, writing logs to %s",
+				logDirectory, fallbackLogDirectory.dir)
+		}
+	})
+	return fallbackLogDirectory.dir, fallbackLogDirectory.err
+}
+
+// characters which aren't safe in log file names, e.g. the slashes of
+// subtests or shell metacharacters, as the names are used in commands
+var unsafeFileNameExp = regexp.MustCompile(`[^A-Za-z0-9._-]+`)
+
+// logFileName returns the path of a test's log file with the label, e.g. a
+// snap instance name like matter-all-clusters-app_rev2. Characters which
+// aren't safe in file names are replaced with "-". The names of subtests get
+// a short hash of the test name, so that tests which only differ by their
+// slashes, e.g. "a/b-c" and "a-b/c", don't share a log file.
 func logFileName(t *testing.T, label string) string {
-	fileName := label + ".log"
+	var prefix string
 	if t != nil {
-		fileName = strings.ReplaceAll(t.Name(), "/", "-") + "-" + fileName
+		prefix = t.Name() + "-"
+		if strings.Contains(t.Name(), "/") {
+			prefix += testNameHash(t.Name()) + "-"
+		}
 	}
-	logDirectory := "logs"
 
-	err := os.MkdirAll(logDirectory, 0777)
+	dir, err := logDir()
 	if err != nil {
-		t.Fatalf("Can't create log directory")
+		if t != nil {
+			t.Fatalf("Can't create log directory: %s", err)
+		}
+		log.Fatalf("Can't create log directory: %s", err)
 	}
 
-	return logDirectory + "/" + fileName
+	name := unsafeFileNameExp.ReplaceAllString(prefix+label, "-")
+	return filepath.Join(dir, name+".log")
 }
 
-func WriteLogFile(t *testing.T, label string, content string) error {
-	return os.WriteFile(
-		logFileName(t, label),
+// testNameHash returns a short hash of a test name
+func testNameHash(name string) string {
+	h := fnv.New32a()
+	h.Write([]byte(name))
+	return fmt.Sprintf("%08x", h.Sum32())
+}
+
+// WriteLogFile writes the content to a log file and returns its path.
+// Invalid UTF-8 bytes, e.g. binary output of a device, are escaped to keep
+// the file parseable.
+func WriteLogFile(t *testing.T, label string, content string) (string, error) {
+	fileName := logFileName(t, label)
+
+	content, escaped := escapeInvalidUTF8(content)
+	if escaped != 0 {
+		msg := fmt.Sprintf("Escaped %d invalid UTF-8 bytes in %s", escaped, fileName)
+		if t != nil {
+			t.Log(msg)
+		} else {
+			log.Print(msg)
+		}
+	}
+
+	return fileName, os.WriteFile(
+		fileName,
 		[]byte(content),
 		0644,
 	)
 }
 
+// escapeInvalidUTF8 replaces invalid UTF-8 bytes with their \xNN escape
+// sequence and returns the number of replaced bytes
+func escapeInvalidUTF8(s string) (string, int) {
+	if utf8.ValidString(s) {
+		return s, 0
+	}
+
+	var b strings.Builder
+	var escaped int
+	for i := 0; i < len(s); {
+		r, size := utf8.DecodeRuneInString(s[i:])
+		if r == utf8.RuneError && size == 1 {
+			fmt.Fprintf(&b, "\\x%02x", s[i])
+			escaped++
+		} else {
+			b.WriteString(s[i : i+size])
+		}
+		i += size
+	}
+	return b.String(), escaped
+}
+
 func WaitForLogMessage(t *testing.T, snap, expectedLog string, since time.Time) {
-	const maxRetry = 10
+	waitForLogMessage(t, snap, expectedLog, since, 10)
+}
 
+// waitForLogMessage waits for up to maxRetry seconds for the expected log
+func waitForLogMessage(t *testing.T, snap, expectedLog string, since time.Time, maxRetry int) {
 	for i := 1; i <= maxRetry; i++ {
 		time.Sleep(1 * time.Second)
 		t.Logf("Retry %d/%d: Waiting for expected content in logs: %s", i, maxRetry, expectedLog)
@@ -46,3 +146,73 @@ func WaitForLogMessage(t *testing.T, snap, expectedLog string, since time.Time)
 
 	t.Fatalf("Time out: reached max %d retries.", maxRetry)
 }
+
+// RequireLogCapture writes a unique sentinel message to the journal and checks
+// that SnapLogs captures it since the given time. This validates the capture
+// window before relying on it, e.g. when test and journald clocks differ.
+func RequireLogCapture(t *testing.T, snap string, since time.Time) {
+	// SnapLogs filters by snap name, so the sentinel has to include it
+	sentinel := fmt.Sprintf("Log capture sentinel for %s: %d", snap, time.Now().UnixNano())
+	Exec(t, fmt.Sprintf("echo '%s' | systemd-cat --identifier=matter-snap-testing", sentinel))
+
+	const maxRetry = 5
+	for i := 1; i <= maxRetry; i++ {
+		if strings.Contains(SnapLogs(t, since, snap), sentinel) {
+			t.Logf("Found log capture sentinel: %s", sentinel)
+			return
+		}
+		time.Sleep(1 * time.Second)
+	}
+
+	t.Fatalf("Log capture since %s missed the sentinel '%s'. "+
+		"Make sure the test and journald clocks match and that the since time is taken before the test starts.",
+		since.Format("2006-01-02 15:04:05"), sentinel)
+}
+
+// RequireNoSecretsInLogs checks that none of the secret values, e.g. setup
+// PIN codes, network keys or PSKs, appear in the snap's logs since the given
+// time. Matching is case-insensitive to catch hex encoded keys in either case.
+// The secrets are redacted in the failure message.
+func RequireNoSecretsInLogs(t *testing.T, snap string, secrets []string, since time.Time) {
+	leaks := findSecrets(SnapLogs(t, since, snap), secrets)
+	for _, leak := range leaks {
+		t.Errorf("Snap %s logged secret %s: %s", snap, redact(leak.secret),
+			strings.ReplaceAll(leak.line, leak.secret, redact(leak.secret)))
+	}
+	if len(leaks) != 0 {
+		t.FailNow()
+	}
+}
+
+type secretLeak struct {
+	secret string
+	line   string
+}
+
+// findSecrets returns the log lines which contain any of the secrets
+func findSecrets(logs string, secrets []string) (leaks []secretLeak) {
+	var exps []*regexp.Regexp
+	for _, secret := range secrets {
+		if secret != "" {
+			exps = append(exps, regexp.MustCompile("(?i)"+regexp.QuoteMeta(secret)))
+		}
+	}
+
+	for _, line := range strings.Split(logs, "\n") {
+		for _, exp := range exps {
+			// as logged, to be able to redact it
+			if logged := exp.FindString(line); logged != "" {
+				leaks = append(leaks, secretLeak{secret: logged, line: line})
+			}
+		}
+	}
+	return leaks
+}
+
+// redact keeps the first two characters of a secret
+func redact(secret string) string {
+	if len(secret) <= 2 {
+		return "***"
+	}
+	return secret[:2] + "***"
+}
@@ -0,0 +1,101 @@
+package utils
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEscapeInvalidUTF8(t *testing.T) {
+	s, escaped := escapeInvalidUTF8("valid ✓")
+	assert.Equal(t, "valid ✓", s)
+	assert.Equal(t, 0, escaped)
+
+	s, escaped = escapeInvalidUTF8("bad \xff\xfe bytes ✓")
+	assert.Equal(t, `bad \xff\xfe bytes ✓`, s)
+	assert.Equal(t, 2, escaped)
+}
+
+func TestFindSecrets(t *testing.T) {
+	logs := `Jan 01 10:00:00 host matter-all-clusters-app.all-clusters-app[1234]: CHIP:SVR: Setup pin code: 20202021
+Jan 01 10:00:00 host matter-all-clusters-app.all-clusters-app[1234]: CHIP:DL: Thread network key: 00112233445566778899aabbccddeeff
+Jan 01 10:00:00 host matter-all-clusters-app.all-clusters-app[1234]: CHIP:DL: Connected
+`
+	leaks := findSecrets(logs, []string{"20202021", "00112233445566778899AABBCCDDEEFF", "", "secret-psk"})
+	assert.Len(t, leaks, 2)
+	assert.Equal(t, "20202021", leaks[0].secret)
+	assert.Equal(t, "00112233445566778899aabbccddeeff", leaks[1].secret)
+
+	assert.Equal(t, "20***", redact("20202021"))
+	assert.Equal(t, "***", redact("ab"))
+}
+
+func TestWriteLogFileFallback(t *testing.T) {
+	defaultDirectory := logDirectory
+	t.Cleanup(func() {
+		logDirectory = defaultDirectory
+	})
+
+	// a directory below a regular file can't be created, even as root
+	file := filepath.Join(t.TempDir(), "file")
+	require.NoError(t, os.WriteFile(file, nil, 0644))
+	logDirectory = filepath.Join(file, "logs")
+
+	path, err := WriteLogFile(t, "fallback", "content")
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		os.RemoveAll(filepath.Dir(path))
+		fallbackLogDirectory.once = sync.Once{}
+	})
+	assert.False(t, strings.HasPrefix(path, logDirectory))
+
+	content, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, "content", string(content))
+}
+
+func TestLogFileNameCollision(t *testing.T) {
+	defaultDirectory := logDirectory
+	t.Cleanup(func() {
+		logDirectory = defaultDirectory
+	})
+	logDirectory = t.TempDir()
+
+	var paths [2]string
+	t.Run("parallel", func(t *testing.T) {
+		// both are named TestLogFileNameCollision-parallel-a-b-c
+		t.Run("a/b-c", func(t *testing.T) {
+			t.Parallel()
+			var err error
+			paths[0], err = WriteLogFile(t, "log", "first")
+			require.NoError(t, err)
+		})
+		t.Run("a-b", func(t *testing.T) {
+			t.Run("c", func(t *testing.T) {
+				t.Parallel()
+				var err error
+				paths[1], err = WriteLogFile(t, "log", "second")
+				require.NoError(t, err)
+			})
+		})
+	})
+
+	// the names don't depend on which test writes first
+	assert.Equal(t, [2]string{
+		filepath.Join(logDirectory, "TestLogFileNameCollision-parallel-a-b-c-2e3ad61b-log.log"),
+		filepath.Join(logDirectory, "TestLogFileNameCollision-parallel-a-b-c-1d25427b-log.log"),
+	}, paths)
+	for i, expected := range []string{"first", "second"} {
+		content, err := os.ReadFile(paths[i])
+		require.NoError(t, err)
+		assert.Equal(t, expected, string(content))
+	}
+
+	// top-level tests have no slashes to disambiguate
+	assert.Equal(t, filepath.Join(logDirectory, "TestLogFileNameCollision-log.log"), logFileName(t, "log"))
+}
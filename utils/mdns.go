@@ -0,0 +1,176 @@
+package utils
+
+import (
+	"bufio"
+	"fmt"
+	goexec "os/exec"
+	"regexp"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+)
+
+const (
+	// DNS-SD service type of commissionable Matter devices
+	MDNSCommissionable = "_matterc._udp"
+	// DNS-SD service type of commissioned Matter devices
+	MDNSOperational = "_matter._tcp"
+)
+
+// MDNSService is a resolved DNS-SD service
+type MDNSService struct {
+	Interface string
+	Protocol  string // IPv4 or IPv6
+	Name      string
+	Type      string
+	Host      string
+	Address   string
+	Port      string
+	// e.g. D=3840 (discriminator), CM=1 (commissioning mode), VP=65521+32769 (vendor+product)
+	TXT map[string]string
+}
+
+// BrowseMDNS returns the resolved services of the given type, e.g. MDNSCommissionable
+func BrowseMDNS(t *testing.T, serviceType string) []MDNSService {
+	stdout, _, _ := Exec(t, fmt.Sprintf(
+		"avahi-browse --resolve --terminate --parsable %s || true",
+		serviceType,
+	))
+	return parseAvahiBrowse(stdout)
+}
+
+var txtRecordExp = regexp.MustCompile(`"([^"]*)"`)
+
+// parseAvahiBrowse parses the resolved entries of avahi-browse's parsable output:
+//
+//	=;eth0;IPv6;2906C908D115D362;_matterc._udp;local;host.local;fe80::1;5540;"D=3840" "CM=1"
+func parseAvahiBrowse(out string) (services []MDNSService) {
+	for _, line := range strings.Split(out, "\n") {
+		fields := strings.SplitN(line, ";", 10)
+		if len(fields) < 9 || fields[0] != "=" {
+			continue
+		}
+
+		service := MDNSService{
+			Interface: fields[1],
+			Protocol:  fields[2],
+			Name:      fields[3],
+			Type:      fields[4],
+			Host:      fields[6],
+			Address:   fields[7],
+			Port:      fields[8],
+			TXT:       make(map[string]string),
+		}
+		if len(fields) == 10 {
+			for _, match := range txtRecordExp.FindAllStringSubmatch(fields[9], -1) {
+				key, value, _ := strings.Cut(match[1], "=")
+				service.TXT[key] = value
+			}
+		}
+		services = append(services, service)
+	}
+	return services
+}
+
+// WaitForMDNS waits for services of the given type to be advertised and
+// returns them
+func WaitForMDNS(t *testing.T, serviceType string, maxRetry int) []MDNSService {
+	for i := 1; i <= maxRetry; i++ {
+		t.Logf("Retry %d/%d: Waiting for mDNS service: %s", i, maxRetry, serviceType)
+
+		if services := BrowseMDNS(t, serviceType); len(services) != 0 {
+			return services
+		}
+		time.Sleep(1 * time.Second)
+	}
+
+	t.Fatalf("Time out: reached max %d retries.", maxRetry)
+	return nil
+}
+
+// RequireMDNSTxt checks that a commissionable device advertises the TXT
+// record with the expected value, e.g. D=3840 for the discriminator
+func RequireMDNSTxt(t *testing.T, key, expected string) {
+	var values []string
+	for _, s := range WaitForMDNS(t, MDNSCommissionable, 10) {
+		if s.TXT[key] == expected {
+			t.Logf("Found %s advertising %s=%s", s.Name, key, expected)
+			return
+		}
+		values = append(values, s.TXT[key])
+	}
+	t.Fatalf("No commissionable device advertises %s=%s, found: %v", key, expected, values)
+}
+
+// MeasureDiscoveryTime stops the device snap, starts it again and returns the
+// time from the start until the device's first commissionable mDNS
+// advertisement was resolved. The advertisement is watched by a continuous
+// avahi-browse, for a better resolution than periodic browsing.
+// The measurement is written to the log directory for trend analysis.
+func MeasureDiscoveryTime(t *testing.T, deviceSnap string, timeout time.Duration) time.Duration {
+	SnapStop(t, deviceSnap)
+
+	command := "avahi-browse --resolve --parsable " + MDNSCommissionable
+	t.Logf("[exec] %s", command)
+	cmd := goexec.Command("/bin/bash", "-c", command)
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = startProcess(t, cmd); err != nil {
+		t.Fatal(err)
+	}
+	defer syscall.Kill(-cmd.Process.Pid, syscall.SIGTERM)
+
+	// times at which entries were resolved
+	resolved := make(chan time.Time, 100)
+	go func() {
+		defer waitProcess(cmd)
+		scanner := bufio.NewScanner(stdout)
+		for scanner.Scan() {
+			if services := parseAvahiBrowse(scanner.Text()); len(services) != 0 {
+				select {
+				case resolved <- time.Now():
+				default:
+				}
+			}
+		}
+	}()
+
+	// let avahi-browse report cached entries first
+	time.Sleep(1 * time.Second)
+	start := time.Now()
+	SnapStart(t, deviceSnap)
+
+	var elapsed time.Duration
+	deadline := time.After(timeout)
+	for elapsed == 0 {
+		select {
+		case at := <-resolved:
+			// ignore stale entries resolved before the start
+			if at.After(start) {
+				elapsed = at.Sub(start)
+			}
+		case <-deadline:
+			t.Fatalf("Time out: %s was not discovered within %s", deviceSnap, timeout)
+		}
+	}
+
+	t.Logf("Discovered %s in %s after start", deviceSnap, elapsed)
+	if _, err := WriteLogFile(t, "mdns-discovery", fmt.Sprintf("discovery_seconds=%.3f\n", elapsed.Seconds())); err != nil {
+		t.Logf("Error writing discovery time: %s", err)
+	}
+	return elapsed
+}
+
+// RequireDiscoveryUnder measures the discovery time of the device snap with
+// MeasureDiscoveryTime and checks that it is under the threshold
+func RequireDiscoveryUnder(t *testing.T, deviceSnap string, threshold time.Duration) time.Duration {
+	// wait a bit longer than the threshold to report the actual discovery time
+	elapsed := MeasureDiscoveryTime(t, deviceSnap, 2*threshold)
+	if elapsed >= threshold {
+		t.Fatalf("Discovery took %s, not under %s", elapsed, threshold)
+	}
+	return elapsed
+}
@@ -0,0 +1,75 @@
+package utils
+
+import (
+	"fmt"
+	"strconv"
+	"testing"
+)
+
+// SnapServiceMemoryLimit limits the memory of a snap service's cgroup, e.g.
+// to 64M, by setting MemoryMax on its systemd unit until test cleanup.
+// The limit applies to the running processes and isn't persisted.
+func SnapServiceMemoryLimit(t *testing.T, snap, service, limit string) {
+	unit := SnapServiceUnit(snap, service)
+
+	t.Cleanup(func() {
+		ExecVerbose(t, fmt.Sprintf("sudo systemctl set-property --runtime %s MemoryMax=infinity", unit))
+	})
+	ExecVerbose(t, fmt.Sprintf("sudo systemctl set-property --runtime %s MemoryMax=%s", unit, limit))
+
+	if value := SnapServiceProperty(t, snap, service, "MemoryMax"); value == "infinity" {
+		t.Fatalf("Failed to limit the memory of %s to %s", unit, limit)
+	}
+}
+
+// snapServiceRestarts returns how many times systemd restarted a snap service
+func snapServiceRestarts(t *testing.T, snap, service string) int {
+	value := SnapServiceProperty(t, snap, service, "NRestarts")
+	restarts, err := strconv.Atoi(value)
+	if err != nil {
+		t.Fatalf("Invalid NRestarts of %s: %s", SnapServiceUnit(snap, service), value)
+	}
+	return restarts
+}
+
+// RequireGracefulUnderMemoryLimit commissions the device of a snap service
+// while its memory is limited and returns the commissioning error, if any.
+// Commissioning may fail under the limit, but the test fails if the service
+// got OOM-killed more than once, i.e. it is in a kill and restart loop, or if
+// it is no longer running afterwards.
+func RequireGracefulUnderMemoryLimit(t *testing.T, snap, service, limit string) error {
+	const nodeID = "110"
+	unit := SnapServiceUnit(snap, service)
+
+	SnapServiceMemoryLimit(t, snap, service, limit)
+	restarts := snapServiceRestarts(t, snap, service)
+
+	err := ChipToolPairOnNetwork(t, nodeID, DefaultSetupPINCode)
+	if err != nil {
+		t.Logf("Commissioning failed under MemoryMax=%s: %s", limit, err)
+	} else {
+		t.Cleanup(func() {
+			ChipToolUnpair(t, nodeID)
+			ChipToolReset(t)
+		})
+	}
+
+	t.Logf("Service %s used at most %s bytes of memory", unit,
+		SnapServiceProperty(t, snap, service, "MemoryPeak"))
+
+	restarted := snapServiceRestarts(t, snap, service) - restarts
+	result := SnapServiceProperty(t, snap, service, "Result")
+	switch {
+	case restarted > 1:
+		t.Fatalf("Service %s restarted %d times under MemoryMax=%s (last result: %s), "+
+			"it is in an OOM-kill loop", unit, restarted, limit, result)
+	case restarted == 1:
+		t.Logf("Warning: service %s restarted once under MemoryMax=%s (last result: %s)",
+			unit, limit, result)
+	}
+
+	if !SnapServicesActive(t, snap+"."+service) {
+		t.Fatalf("Service %s is not running under MemoryMax=%s (last result: %s)", unit, limit, result)
+	}
+	return err
+}
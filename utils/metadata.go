@@ -0,0 +1,76 @@
+package utils
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/canonical/matter-snap-testing/env"
+)
+
+// RunMetadata describes the environment of a test run
+type RunMetadata struct {
+	Time         time.Time         `json:"time"`
+	OS           string            `json:"os"`
+	Kernel       string            `json:"kernel"`
+	SnapdVersion string            `json:"snapd_version"`
+	Snaps        []SnapMetadata    `json:"snaps"`
+	Env          map[string]string `json:"env"`
+}
+
+// SnapMetadata describes an installed snap
+type SnapMetadata struct {
+	Name     string `json:"name"`
+	Version  string `json:"version"`
+	Revision string `json:"revision"`
+	Tracking string `json:"tracking"`
+	Base     string `json:"base"`
+}
+
+// WriteRunMetadata writes the OS, kernel, snapd version, details of the given
+// snaps and the environment variable settings to <dir>/metadata.json, making
+// the run's artifacts self-describing. It is meant to be called from TestMain.
+func WriteRunMetadata(dir string, snaps ...string) error {
+	metadata := RunMetadata{
+		Time:         time.Now(),
+		SnapdVersion: SnapdVersion(nil),
+		Env: map[string]string{
+			env.EnvSnapChannel:      env.SnapChannel(),
+			env.EnvSnapPath:         env.SnapPath(),
+			env.EnvTeardown:         strconv.FormatBool(env.Teardown()),
+			env.EnvFullConfigTest:   strconv.FormatBool(env.FullConfigTest()),
+			env.EnvSnapStoreProxy:   env.SnapStoreProxy(),
+			env.EnvMaxSuiteDuration: env.MaxSuiteDuration().String(),
+			env.EnvProfile:          strconv.FormatBool(env.Profile()),
+			env.EnvRebootResume:     strconv.FormatBool(env.RebootResume()),
+		},
+	}
+
+	osRelease, _, _ := Exec(nil, ". /etc/os-release && echo $PRETTY_NAME")
+	metadata.OS = strings.TrimSpace(osRelease)
+	kernel, _, _ := Exec(nil, "uname -r")
+	metadata.Kernel = strings.TrimSpace(kernel)
+
+	for _, snap := range snaps {
+		info := SnapInfo(nil, snap)
+		metadata.Snaps = append(metadata.Snaps, SnapMetadata{
+			Name:     snap,
+			Version:  info.Version,
+			Revision: info.Revision,
+			Tracking: info.Tracking,
+			Base:     info.Base,
+		})
+	}
+
+	content, err := json.MarshalIndent(metadata, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(dir, 0777); err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(dir, "metadata.json"), content, 0644)
+}
@@ -0,0 +1,116 @@
+package utils
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// HTTPGet waits for the local port to accept connections and returns the body
+// of a successful GET response for the path
+func HTTPGet(t *testing.T, port, path string) string {
+	WaitServiceOnline(t, 60, port)
+
+	url := fmt.Sprintf("http://localhost:%s%s", port, path)
+	t.Logf("[http] GET %s", url)
+
+	client := http.Client{Timeout: 10 * time.Second}
+	resp, err := client.Get(url)
+	if err != nil {
+		t.Fatalf("Error getting %s: %s", url, err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatalf("Error reading response of %s: %s", url, err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("Unexpected response status of %s: %s: %s", url, resp.Status, body)
+	}
+	return string(body)
+}
+
+// MetricSample is a sample of a metric, with its labels as printed,
+// e.g. {service="chip-tool"}
+type MetricSample struct {
+	Labels string
+	Value  float64
+}
+
+// Metrics are the samples of metrics by name
+type Metrics map[string][]MetricSample
+
+// ScrapeMetrics fetches and parses metrics in the Prometheus text format
+// from a local port, e.g. path /metrics
+func ScrapeMetrics(t *testing.T, port, path string) Metrics {
+	metrics, err := parseMetrics(HTTPGet(t, port, path))
+	if err != nil {
+		t.Fatalf("Error parsing metrics: %s", err)
+	}
+	return metrics
+}
+
+// parseMetrics parses the Prometheus text format:
+//
+//	# TYPE http_requests_total counter
+//	http_requests_total{method="post",code="200"} 1027 1395066363000
+func parseMetrics(body string) (Metrics, error) {
+	metrics := make(Metrics)
+	for _, line := range strings.Split(body, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		name, labels, rest := line, "", ""
+		if i := strings.Index(line, "{"); i != -1 {
+			j := strings.LastIndex(line, "}")
+			if j < i {
+				return nil, fmt.Errorf("unterminated labels: %s", line)
+			}
+			name, labels, rest = line[:i], line[i:j+1], line[j+1:]
+		} else {
+			name, rest, _ = strings.Cut(line, " ")
+		}
+
+		// value followed by an optional timestamp
+		fields := strings.Fields(rest)
+		if len(fields) == 0 {
+			return nil, fmt.Errorf("missing value: %s", line)
+		}
+		value, err := strconv.ParseFloat(fields[0], 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid value: %s", line)
+		}
+
+		metrics[name] = append(metrics[name], MetricSample{Labels: labels, Value: value})
+	}
+	return metrics, nil
+}
+
+// RequireMetricPresent checks that the metric has at least one sample
+func RequireMetricPresent(t *testing.T, metrics Metrics, name string) {
+	if len(metrics[name]) == 0 {
+		t.Fatalf("Metric %s is not present", name)
+	}
+}
+
+// RequireMetricValue checks the value of a metric's sample with the given
+// labels, or with no labels if empty
+func RequireMetricValue(t *testing.T, metrics Metrics, name, labels string, expected float64) {
+	RequireMetricPresent(t, metrics, name)
+	for _, s := range metrics[name] {
+		if s.Labels == labels {
+			if s.Value != expected {
+				t.Fatalf("Metric %s%s is %v instead of %v", name, labels, s.Value, expected)
+			}
+			return
+		}
+	}
+	t.Fatalf("Metric %s has no sample with labels '%s'", name, labels)
+}
@@ -0,0 +1,28 @@
+package utils
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseMetrics(t *testing.T) {
+	metrics, err := parseMetrics(`
+# HELP http_requests_total The total number of HTTP requests.
+# TYPE http_requests_total counter
+http_requests_total{method="post",code="200"} 1027 1395066363000
+http_requests_total{method="post",code="400"}    3 1395066363000
+process_open_fds 12
+`)
+	require.NoError(t, err)
+
+	assert.Equal(t, []MetricSample{
+		{Labels: `{method="post",code="200"}`, Value: 1027},
+		{Labels: `{method="post",code="400"}`, Value: 3},
+	}, metrics["http_requests_total"])
+	assert.Equal(t, []MetricSample{{Value: 12}}, metrics["process_open_fds"])
+
+	_, err = parseMetrics("process_open_fds twelve")
+	assert.Error(t, err)
+}
@@ -1,18 +1,25 @@
 package utils
 
 import (
+	"context"
 	"fmt"
 	"log"
 	"net"
+	"slices"
 	"strings"
+	"syscall"
 	"testing"
 	"time"
+
+	"github.com/canonical/matter-snap-testing/env"
 )
 
 type Net struct {
 	StartSnap        bool // should be set to true if services aren't started by default
 	TestOpenPorts    []string
 	TestBindLoopback []string
+	// compare listening ports under strict and devmode confinement, requires full config test
+	TestConfinementPorts bool
 }
 
 const dialTimeout = 2 * time.Second
@@ -47,6 +54,9 @@ func TestNet(t *testing.T, snapName string, conf Net) {
 		if len(conf.TestBindLoopback) > 0 {
 			testBindLoopback(t, snapName, conf.TestBindLoopback)
 		}
+		if conf.TestConfinementPorts {
+			testConfinementPorts(t, snapName, conf.TestOpenPorts)
+		}
 
 	})
 }
@@ -70,9 +80,61 @@ func testBindLoopback(t *testing.T, snapName string, ports []string) {
 	})
 }
 
+func testConfinementPorts(t *testing.T, snapName string, waitPorts []string) {
+	t.Run("ports under strict and devmode confinement", func(t *testing.T) {
+		if !env.FullConfigTest() {
+			t.Skip("Full config test is disabled by " + env.EnvFullConfigTest)
+		}
+
+		listeningPorts := func(options ...string) []string {
+			SnapRemove(t, snapName)
+			if err := SnapInstallFromEnv(t, snapName, options...); err != nil {
+				t.Fatalf("Error installing snap: %s", err)
+			}
+			SnapStart(t, snapName)
+			WaitServiceOnline(t, 60, waitPorts...)
+			return SnapListeningPorts(t, snapName)
+		}
+
+		t.Cleanup(func() {
+			SnapRemove(t, snapName)
+			SnapInstallFromEnv(t, snapName)
+		})
+
+		strictPorts := listeningPorts()
+		devmodePorts := listeningPorts("--devmode")
+		t.Logf("Listening ports with strict confinement: %v", strictPorts)
+		t.Logf("Listening ports with devmode confinement: %v", devmodePorts)
+
+		for _, p := range devmodePorts {
+			if !slices.Contains(strictPorts, p) {
+				t.Errorf("Port %s is only open with devmode confinement", p)
+			}
+		}
+		for _, p := range strictPorts {
+			if !slices.Contains(devmodePorts, p) {
+				t.Errorf("Port %s is only open with strict confinement", p)
+			}
+		}
+	})
+}
+
 // WaitServiceOnline waits for a service to come online by dialing its port(s)
 // up to a maximum number
 func WaitServiceOnline(t *testing.T, maxRetry int, ports ...string) error {
+	_, err := waitServiceOnline(t, maxRetry, ports...)
+	return err
+}
+
+// WaitServiceOnlineElapsed is like WaitServiceOnline and additionally returns
+// the time it took until all ports accepted connections
+func WaitServiceOnlineElapsed(t *testing.T, maxRetry int, ports ...string) (time.Duration, error) {
+	return waitServiceOnline(t, maxRetry, ports...)
+}
+
+func waitServiceOnline(t *testing.T, maxRetry int, ports ...string) (time.Duration, error) {
+	start := time.Now()
+
 	closedPorts := make([]string, len(ports))
 	copy(closedPorts, ports)
 
@@ -109,7 +171,7 @@ func WaitServiceOnline(t *testing.T, maxRetry int, ports ...string) error {
 		closedPorts = closedPortsTemp
 
 		if len(closedPorts) == 0 {
-			return nil
+			return time.Since(start), nil
 		}
 
 		time.Sleep(1 * time.Second)
@@ -124,10 +186,27 @@ func WaitServiceOnline(t *testing.T, maxRetry int, ports ...string) error {
 	if t != nil {
 		t.Fatal(err)
 	} else {
-		return err
+		return time.Since(start), err
 	}
 
-	return nil
+	return time.Since(start), nil
+}
+
+// RequireStartupUnder waits for the port(s) to accept connections and checks
+// that it happened within the threshold since start, i.e. the time taken right
+// before starting or installing the snap.
+// The measurement has the one second resolution of the port checks.
+func RequireStartupUnder(t *testing.T, start time.Time, threshold time.Duration, ports ...string) time.Duration {
+	// wait a bit longer than the threshold to report the actual startup time
+	maxRetry := max(int(2*threshold/time.Second), 1)
+	WaitServiceOnline(t, maxRetry, ports...)
+
+	elapsed := time.Since(start)
+	t.Logf("Startup took %s until ports opened: %s", elapsed, strings.Join(ports, ", "))
+	if elapsed >= threshold {
+		t.Fatalf("Startup took %s, not under %s", elapsed, threshold)
+	}
+	return elapsed
 }
 
 // requirePortOpen checks if the local port(s) accepts connections
@@ -202,6 +281,111 @@ func RequirePortAvailable(t *testing.T, port string) {
 	t.Logf("Port %s is available.", port)
 }
 
+// RequirePortNotOpen checks that nothing listens on a local TCP or UDP port
+// throughout the given duration, e.g. for a feature disabled via config.
+// It is the inverse of WaitServiceOnline.
+func RequirePortNotOpen(t *testing.T, port string, duration time.Duration) {
+	deadline := time.Now().Add(duration)
+	for {
+		for _, l := range Listeners(t) {
+			if l.Port == port {
+				t.Fatalf("Port %s was opened by %s (%s)", l.key(), l.Command, l.PID)
+			}
+		}
+		if time.Now().After(deadline) {
+			break
+		}
+		time.Sleep(1 * time.Second)
+	}
+	t.Logf("Port %s stayed closed for %s", port, duration)
+}
+
+// WaitPortListening waits for a process to listen on a local TCP or UDP port.
+// Unlike WaitServiceOnline, it doesn't connect so it also works for UDP.
+func WaitPortListening(t *testing.T, maxRetry int, port string) {
+	for i := 1; i <= maxRetry; i++ {
+		t.Logf("Retry %d/%d: Waiting for port: %s", i, maxRetry, port)
+		for _, l := range Listeners(t) {
+			if l.Port == port {
+				t.Logf("Port %s is open by %s (%s)", l.key(), l.Command, l.PID)
+				return
+			}
+		}
+		time.Sleep(1 * time.Second)
+	}
+	t.Fatalf("Time out: reached max %d retries.", maxRetry)
+}
+
+// TestPortClosedUntilCommissioned installs the device snap and checks that
+// its operational port stays closed until the device gets commissioned
+func TestPortClosedUntilCommissioned(t *testing.T, snapName, nodeID, port string) {
+	t.Run("port closed until commissioned", func(t *testing.T) {
+		t.Cleanup(func() {
+			ChipToolReset(t)
+		})
+
+		SnapRemove(t, snapName)
+		if err := SnapInstallFromEnv(t, snapName); err != nil {
+			t.Fatalf("Error installing snap: %s", err)
+		}
+		SnapStart(t, snapName)
+
+		RequirePortNotOpen(t, port, 10*time.Second)
+
+		if err := ChipToolPairOnNetwork(t, nodeID, DefaultSetupPINCode); err != nil {
+			t.Fatalf("Error commissioning: %s", err)
+		}
+		WaitPortListening(t, 60, port)
+	})
+}
+
+// RequirePortRebindable waits until a local port can be bound again for TCP
+// and UDP, e.g. after stopping the service which held it, and returns how
+// long it took. The TCP bind doesn't set SO_REUSEADDR, so it also waits for
+// connections in TIME_WAIT, which would block services that don't set it.
+func RequirePortRebindable(t *testing.T, port string, timeout time.Duration) time.Duration {
+	start := time.Now()
+	var err error
+	for time.Since(start) < timeout {
+		if err = tryBind(port); err == nil {
+			elapsed := time.Since(start)
+			t.Logf("Port %s became bindable after %s", port, elapsed)
+			return elapsed
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+	t.Fatalf("Time out: port %s did not become bindable within %s: %s", port, timeout, err)
+	return 0
+}
+
+// tryBind binds the port for TCP and UDP and releases it
+func tryBind(port string) error {
+	config := net.ListenConfig{
+		Control: func(network, address string, c syscall.RawConn) error {
+			var sockErr error
+			err := c.Control(func(fd uintptr) {
+				sockErr = syscall.SetsockoptInt(int(fd), syscall.SOL_SOCKET, syscall.SO_REUSEADDR, 0)
+			})
+			if err != nil {
+				return err
+			}
+			return sockErr
+		},
+	}
+
+	listener, err := config.Listen(context.Background(), "tcp", ":"+port)
+	if err != nil {
+		return err
+	}
+	listener.Close()
+
+	conn, err := config.ListenPacket(context.Background(), "udp", ":"+port)
+	if err != nil {
+		return err
+	}
+	return conn.Close()
+}
+
 func isListenInterface(t *testing.T, addr string, port string) bool {
 	list := filterOpenPorts(t, port)
 
@@ -226,3 +410,138 @@ func lsof(t *testing.T, port string) string {
 	stdout, _, _ := Exec(t, fmt.Sprintf("sudo lsof -nPi :%s || true", port))
 	return stdout
 }
+
+// Listener is a listening socket reported by lsof
+type Listener struct {
+	Command  string
+	PID      string
+	Protocol string // TCP or UDP
+	Address  string
+	Port     string
+}
+
+// key returns the port and protocol of the listener, e.g. 5540/udp
+func (l Listener) key() string {
+	return l.Port + "/" + strings.ToLower(l.Protocol)
+}
+
+// Listeners returns the local listening TCP sockets and bound UDP sockets
+func Listeners(t *testing.T) []Listener {
+	// The chained true command is to make sure execution succeeds even if
+	// 	the first command fails when list is empty
+	stdout, _, _ := Exec(t, "sudo lsof -nP -iTCP -sTCP:LISTEN -iUDP || true")
+	return parseLsof(stdout)
+}
+
+func parseLsof(out string) (listeners []Listener) {
+	for _, line := range strings.Split(out, "\n") {
+		// COMMAND PID USER FD TYPE DEVICE SIZE/OFF NODE NAME
+		fields := strings.Fields(line)
+		if len(fields) < 9 || fields[0] == "COMMAND" {
+			continue
+		}
+
+		name := fields[8]
+		// skip connected sockets
+		if strings.Contains(name, "->") {
+			continue
+		}
+		i := strings.LastIndex(name, ":")
+		if i == -1 {
+			continue
+		}
+
+		listeners = append(listeners, Listener{
+			Command:  fields[0],
+			PID:      fields[1],
+			Protocol: fields[7],
+			Address:  name[:i],
+			Port:     name[i+1:],
+		})
+	}
+	return listeners
+}
+
+// SnapListeningPorts returns the sorted ports the snap's processes listen on,
+// formatted as <port>/<protocol>, e.g. 5540/udp
+func SnapListeningPorts(t *testing.T, snapName string) []string {
+	pids := SnapPIDs(t, snapName)
+
+	var ports []string
+	for _, l := range Listeners(t) {
+		p := l.key()
+		if slices.Contains(pids, l.PID) && !slices.Contains(ports, p) {
+			ports = append(ports, p)
+		}
+	}
+	slices.Sort(ports)
+	return ports
+}
+
+// RequireNoPortConflict checks how two snaps use a local port. If shared is
+// false, at most one of them may listen on it. If shared is true, both must
+// listen on it, as expected from sockets bound with SO_REUSEPORT, e.g. mDNS
+// on 5353/udp. On failure, it reports which snap or process holds the port.
+func RequireNoPortConflict(t *testing.T, snapA, snapB, port string, shared bool) {
+	pidsA := SnapPIDs(t, snapA)
+	pidsB := SnapPIDs(t, snapB)
+
+	var heldByA, heldByB bool
+	var holders []string
+	for _, l := range Listeners(t) {
+		if l.Port != port {
+			continue
+		}
+		switch {
+		case slices.Contains(pidsA, l.PID):
+			heldByA = true
+			holders = append(holders, fmt.Sprintf("%s (%s %s)", snapA, l.Command, l.PID))
+		case slices.Contains(pidsB, l.PID):
+			heldByB = true
+			holders = append(holders, fmt.Sprintf("%s (%s %s)", snapB, l.Command, l.PID))
+		default:
+			holders = append(holders, fmt.Sprintf("%s %s", l.Command, l.PID))
+		}
+	}
+	t.Logf("Port %s is held by: %s", port, strings.Join(holders, ", "))
+
+	if shared && !(heldByA && heldByB) {
+		t.Fatalf("Port %s is not shared by %s and %s, held by: %s",
+			port, snapA, snapB, strings.Join(holders, ", "))
+	}
+	if !shared && heldByA && heldByB {
+		t.Fatalf("Port %s is held by both %s and %s: %s",
+			port, snapA, snapB, strings.Join(holders, ", "))
+	}
+}
+
+// PortSet is a set of listening ports, formatted as <port>/<protocol>
+type PortSet map[string]bool
+
+// PortSnapshot returns the ports currently listened on by any process
+func PortSnapshot(t *testing.T) PortSet {
+	set := make(PortSet)
+	for _, l := range Listeners(t) {
+		set[l.key()] = true
+	}
+	return set
+}
+
+// Diff returns the sorted ports which are in after but not in the set, i.e.
+// were opened, and the ports which are in the set but not in after, i.e.
+// were closed
+func (s PortSet) Diff(after PortSet) (opened, closed []string) {
+	for p := range after {
+		if !s[p] {
+			opened = append(opened, p)
+		}
+	}
+	for p := range s {
+		if !after[p] {
+			closed = append(closed, p)
+		}
+	}
+	slices.Sort(opened)
+	slices.Sort(closed)
+	return opened, closed
+}
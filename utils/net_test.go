@@ -0,0 +1,62 @@
+package utils
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseLsof(t *testing.T) {
+	listeners := parseLsof(`COMMAND     PID  USER   FD   TYPE DEVICE SIZE/OFF NODE NAME
+chip-tool  1234  root    7u  IPv6  56789      0t0  UDP *:5540
+chip-tool  1234  root    8u  IPv4  56790      0t0  TCP 127.0.0.1:5550 (LISTEN)
+avahi-dae   567 avahi   12u  IPv4  12345      0t0  UDP *:5353
+chip-tool  1234  root    9u  IPv6  56791      0t0  UDP [fe80::1]:5540->[fe80::2]:5540
+`)
+	require.Len(t, listeners, 3)
+
+	assert.Equal(t, Listener{"chip-tool", "1234", "UDP", "*", "5540"}, listeners[0])
+	assert.Equal(t, Listener{"chip-tool", "1234", "TCP", "127.0.0.1", "5550"}, listeners[1])
+	assert.Equal(t, "567", listeners[2].PID)
+}
+
+func TestParseAvahiBrowse(t *testing.T) {
+	services := parseAvahiBrowse(`+;eth0;IPv6;2906C908D115D362;_matterc._udp;local
+=;eth0;IPv6;2906C908D115D362;_matterc._udp;local;host.local;fe80::1;5540;"VP=65521+32769" "D=3840" "CM=1"
+`)
+	require.Len(t, services, 1)
+
+	assert.Equal(t, "2906C908D115D362", services[0].Name)
+	assert.Equal(t, "fe80::1", services[0].Address)
+	assert.Equal(t, "5540", services[0].Port)
+	assert.Equal(t, map[string]string{
+		"VP": "65521+32769",
+		"D":  "3840",
+		"CM": "1",
+	}, services[0].TXT)
+}
+
+func TestPortSetDiff(t *testing.T) {
+	before := PortSet{"5353/udp": true, "5550/tcp": true}
+	after := PortSet{"5353/udp": true, "5540/udp": true, "5541/udp": true}
+
+	opened, closed := before.Diff(after)
+	assert.Equal(t, []string{"5540/udp", "5541/udp"}, opened)
+	assert.Equal(t, []string{"5550/tcp"}, closed)
+}
+
+func TestRequirePortRebindable(t *testing.T) {
+	listener, err := net.Listen("tcp", ":0")
+	require.NoError(t, err)
+	_, port, err := net.SplitHostPort(listener.Addr().String())
+	require.NoError(t, err)
+
+	require.Error(t, tryBind(port))
+
+	listener.Close()
+	elapsed := RequirePortRebindable(t, port, 5*time.Second)
+	assert.Less(t, elapsed, 5*time.Second)
+}
@@ -0,0 +1,64 @@
+package utils
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+// ChipToolAllowOTAQueries grants every node on the fabric the operate
+// privilege on the OTA provider, so that requestors can query it for updates
+func ChipToolAllowOTAQueries(t *testing.T, providerNodeID string) error {
+	acl, err := encodeACL([]ACLEntry{
+		{Privilege: ACLPrivilegeAdminister, AuthMode: ACLAuthModeCASE, Subjects: []uint64{chipToolAdminNodeID}},
+		{Privilege: ACLPrivilegeOperate, AuthMode: ACLAuthModeCASE},
+	})
+	if err != nil {
+		return err
+	}
+
+	_, stderr, err := ChipTool(t, fmt.Sprintf(
+		"accesscontrol write acl '%s' %s 0",
+		acl,
+		providerNodeID,
+	))
+	if err != nil {
+		return fmt.Errorf("%s: %s", err, stderr)
+	}
+	return nil
+}
+
+// ChipToolAnnounceOTAProvider announces the OTA provider to the requestor,
+// prompting it to query the provider for an update
+func ChipToolAnnounceOTAProvider(t *testing.T, providerNodeID, requestorNodeID string) error {
+	// <provider node> <vendor id> <announcement reason> <provider endpoint> <requestor node> <requestor endpoint>
+	_, stderr, err := ChipTool(t, fmt.Sprintf(
+		"otasoftwareupdaterequestor announce-otaprovider %s 0 0 0 %s 0",
+		providerNodeID,
+		requestorNodeID,
+	))
+	if err != nil {
+		return fmt.Errorf("%s: %s", err, stderr)
+	}
+	return nil
+}
+
+// RequireOTASuccess performs an OTA software update of a commissioned device
+// from a commissioned OTA provider app which serves the update image.
+// It waits for the device snap to log applying and completing the update.
+func RequireOTASuccess(t *testing.T, deviceSnap, providerNodeID, requestorNodeID string) {
+	// allow enough time to transfer the image
+	const maxRetry = 300
+
+	start := time.Now()
+
+	if err := ChipToolAllowOTAQueries(t, providerNodeID); err != nil {
+		t.Fatalf("Error setting OTA provider access control: %s", err)
+	}
+	if err := ChipToolAnnounceOTAProvider(t, providerNodeID, requestorNodeID); err != nil {
+		t.Fatalf("Error announcing OTA provider: %s", err)
+	}
+
+	waitForLogMessage(t, deviceSnap, "Applying update", start, maxRetry)
+	waitForLogMessage(t, deviceSnap, "Update applied", start, maxRetry)
+}
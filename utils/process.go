@@ -0,0 +1,205 @@
+package utils
+
+import (
+	"log"
+	"os"
+	goexec "os/exec"
+	"os/signal"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"testing"
+
+	"github.com/canonical/matter-snap-testing/env"
+)
+
+// processes started by the helpers, e.g. log followers, chip-tool sessions
+// and executed commands, which are killed if the test binary exits
+// abnormally
+var processes = struct {
+	sync.Mutex
+	cmds map[*goexec.Cmd]trackedProcess
+}{cmds: make(map[*goexec.Cmd]trackedProcess)}
+
+type trackedProcess struct {
+	// whether the process has its own process group
+	group bool
+	// name of the test which started the process, if any
+	test string
+}
+
+// testName returns the name of a test, or an empty string if t is nil
+func testName(t *testing.T) string {
+	if t == nil {
+		return ""
+	}
+	return t.Name()
+}
+
+// startProcess starts a background command in its own process group, to be
+// able to kill sudo and its children together, and tracks it until it exits.
+// If a test is given, the process group is killed on the test's cleanup,
+// which also runs when the test panics or fails with t.FailNow.
+func startProcess(t *testing.T, cmd *goexec.Cmd) error {
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+
+	processes.Lock()
+	defer processes.Unlock()
+
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+	processes.cmds[cmd] = trackedProcess{group: true, test: testName(t)}
+
+	if t != nil {
+		t.Cleanup(func() {
+			killProcess(cmd)
+		})
+	}
+	return nil
+}
+
+// startCommand starts a command like startProcess, but in the process group
+// of the test binary, so that e.g. sudo can still prompt on the terminal.
+// Executed commands are waited for anyway, so no cleanup is registered.
+func startCommand(t *testing.T, cmd *goexec.Cmd) error {
+	processes.Lock()
+	defer processes.Unlock()
+
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+	processes.cmds[cmd] = trackedProcess{test: testName(t)}
+	return nil
+}
+
+// killProcess kills a tracked command with its children, unless it has
+// exited already
+func killProcess(cmd *goexec.Cmd) {
+	processes.Lock()
+	defer processes.Unlock()
+
+	if p, found := processes.cmds[cmd]; found {
+		kill(cmd, p.group)
+	}
+}
+
+// killTestProcesses kills the tracked processes started by a test or its
+// subtests that are still running, with their children
+func killTestProcesses(name string) {
+	processes.Lock()
+	defer processes.Unlock()
+
+	for cmd, p := range processes.cmds {
+		if p.test == name || strings.HasPrefix(p.test, name+"/") {
+			kill(cmd, p.group)
+		}
+	}
+}
+
+// kill kills the process group of a command, or the command and its
+// descendants if it has no group of its own
+func kill(cmd *goexec.Cmd, group bool) {
+	pid := cmd.Process.Pid
+	log.Printf("Killing process %d: %s", pid, cmd.String())
+	if group {
+		syscall.Kill(-pid, syscall.SIGKILL)
+		return
+	}
+	for _, p := range append(descendants(pid), pid) {
+		syscall.Kill(p, syscall.SIGKILL)
+	}
+}
+
+// descendants returns the IDs of the descendant processes of a process
+func descendants(pid int) (pids []int) {
+	children := make(map[int][]int)
+	stats, _ := filepath.Glob("/proc/[0-9]*/stat")
+	for _, path := range stats {
+		stat, err := os.ReadFile(path)
+		if err != nil {
+			continue
+		}
+		// <pid> (<comm>) <state> <ppid> ...
+		id, rest, found := strings.Cut(string(stat), " (")
+		_, rest, _ = strings.Cut(rest, ") ")
+		fields := strings.Fields(rest)
+		if !found || len(fields) < 2 {
+			continue
+		}
+		child, err1 := strconv.Atoi(id)
+		parent, err2 := strconv.Atoi(fields[1])
+		if err1 == nil && err2 == nil {
+			children[parent] = append(children[parent], child)
+		}
+	}
+
+	for queue := children[pid]; len(queue) > 0; queue = queue[1:] {
+		pids = append(pids, queue[0])
+		queue = append(queue, children[queue[0]]...)
+	}
+	return pids
+}
+
+// waitProcess waits for a command started with startProcess or startCommand
+// to exit
+func waitProcess(cmd *goexec.Cmd) error {
+	err := cmd.Wait()
+
+	processes.Lock()
+	delete(processes.cmds, cmd)
+	processes.Unlock()
+	return err
+}
+
+// KillTrackedProcesses kills all processes started by the helpers that are
+// still running, with their children
+func KillTrackedProcesses() {
+	processes.Lock()
+	defer processes.Unlock()
+
+	for cmd, p := range processes.cmds {
+		kill(cmd, p.group)
+	}
+}
+
+// RunTests runs the tests and kills the tracked background processes when the
+// run ends or is interrupted with SIGINT or SIGTERM. It is meant to be called
+// from TestMain in place of m.Run():
+//
+//	os.Exit(utils.RunTests(m))
+//
+// The processes of a panicking test are killed by the test's cleanup, see
+// startProcess, since a panic exits the test binary before RunTests returns.
+// If env.MaxSuiteDuration is set, the run is aborted once it is exceeded.
+func RunTests(m interface{ Run() int }) int {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM)
+	done := make(chan struct{})
+	defer func() {
+		signal.Stop(signals)
+		close(done)
+	}()
+
+	go func() {
+		select {
+		case sig := <-signals:
+			log.Printf("Received %s", sig)
+			KillTrackedProcesses()
+			os.Exit(1)
+		case <-done:
+		}
+	}()
+
+	if budget := env.MaxSuiteDuration(); budget > 0 {
+		watchdog := startSuiteWatchdog(budget)
+		defer watchdog.Stop()
+	}
+
+	// processes started without a test, e.g. from TestMain
+	defer KillTrackedProcesses()
+
+	return m.Run()
+}
@@ -0,0 +1,102 @@
+package utils
+
+import (
+	"fmt"
+	"os"
+	goexec "os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// set to the name of a child test, for the test binary run by the parent
+// test, e.g. to check how the helpers behave when a test panics
+const childTestEnv = "UTILS_CHILD_TEST"
+
+func TestMain(m *testing.M) {
+	os.Exit(RunTests(m))
+}
+
+// runChildTest runs a child test in a new test binary and returns its output
+// and exit error
+func runChildTest(t *testing.T, name string) (string, error) {
+	cmd := goexec.Command(os.Args[0], "-test.run=^"+name+"$", "-test.v")
+	cmd.Env = append(os.Environ(), childTestEnv+"="+name)
+	output, err := cmd.CombinedOutput()
+	t.Logf("Output of child test %s:\n%s", name, output)
+	return string(output), err
+}
+
+// childTest skips a child test unless it runs in the test binary of its
+// parent test
+func childTest(t *testing.T) {
+	if os.Getenv(childTestEnv) != t.Name() {
+		t.Skip("Child test, run by its parent test")
+	}
+}
+
+// fakeSudo replaces sudo in the PATH with a script which runs the command,
+// which may not be installed
+func fakeSudo(t *testing.T, script string) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "sudo"), []byte("#!/bin/sh\n"+script+"\n"), 0755))
+	t.Setenv("PATH", dir+":"+os.Getenv("PATH"))
+}
+
+// processGroupAlive returns whether a process group has processes that
+// aren't zombies
+func processGroupAlive(pgid int) bool {
+	stats, _ := filepath.Glob("/proc/[0-9]*/stat")
+	for _, path := range stats {
+		stat, err := os.ReadFile(path)
+		if err != nil {
+			continue
+		}
+		// <pid> (<comm>) <state> <ppid> <pgrp> ...
+		_, rest, found := strings.Cut(string(stat), ") ")
+		fields := strings.Fields(rest)
+		if !found || len(fields) < 3 {
+			continue
+		}
+		if fields[0] != "Z" && fields[2] == strconv.Itoa(pgid) {
+			return true
+		}
+	}
+	return false
+}
+
+const backgroundProcessMarker = "background process group:"
+
+func TestPanicChild(t *testing.T) {
+	childTest(t)
+
+	// a journal follower that never ends
+	fakeSudo(t, "exec sleep 60")
+	f := FollowSnapLogs(t, "panic-test-snap")
+	fmt.Println(backgroundProcessMarker, f.cmd.Process.Pid)
+
+	panic("deliberate panic")
+}
+
+func TestKillProcessesOfPanickingTest(t *testing.T) {
+	output, err := runChildTest(t, "TestPanicChild")
+	require.Error(t, err, "exit status of panicking test")
+	require.Contains(t, output, "panic: deliberate panic")
+
+	var pgid int
+	for _, line := range strings.Split(output, "\n") {
+		if _, after, found := strings.Cut(line, backgroundProcessMarker); found {
+			pgid, err = strconv.Atoi(strings.TrimSpace(after))
+			require.NoError(t, err)
+		}
+	}
+	require.NotZero(t, pgid, "process group of the follower in the output")
+
+	require.Eventually(t, func() bool {
+		return !processGroupAlive(pgid)
+	}, 5*time.Second, 100*time.Millisecond, "processes of group %d orphaned", pgid)
+}
@@ -0,0 +1,92 @@
+package utils
+
+import (
+	"fmt"
+	"os"
+	goexec "os/exec"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/canonical/matter-snap-testing/env"
+)
+
+// ProfileSnap runs the operation, e.g. commissioning, while recording a CPU
+// profile of the snap's processes with `perf record`, if enabled via
+// env.Profile. It writes the perf.data file and its `perf script` output,
+// e.g. for generating a flamegraph, to the log directory and returns the
+// path of the perf.data file.
+//
+// If profiling is disabled or perf isn't available, the operation runs
+// without profiling and the returned path is empty.
+func ProfileSnap(t *testing.T, snap, label string, operation func()) string {
+	if !env.Profile() {
+		operation()
+		return ""
+	}
+	if _, _, err := Exec(nil, "command -v perf"); err != nil {
+		t.Logf("Warning: %s is set but perf is not installed, skipping profile", env.EnvProfile)
+		operation()
+		return ""
+	}
+
+	pids := SnapPIDs(t, snap)
+	if len(pids) == 0 {
+		t.Fatalf("Snap %s has no running processes to profile", snap)
+	}
+
+	path := strings.TrimSuffix(logFileName(t, label), ".log") + ".perf.data"
+	command := fmt.Sprintf(
+		"sudo perf record --freq=99 --call-graph=dwarf --pid=%s --output=%s",
+		strings.Join(pids, ","),
+		path,
+	)
+	t.Logf("[exec] %s", command)
+
+	cmd := goexec.Command("/bin/bash", "-c", command)
+	if err := startProcess(t, cmd); err != nil {
+		t.Fatalf("Error starting perf: %s", err)
+	}
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		waitProcess(cmd)
+	}()
+
+	operation()
+
+	// stop the recording; sudo relays the signal to perf
+	cmd.Process.Signal(os.Interrupt)
+	select {
+	case <-done:
+	case <-time.After(30 * time.Second):
+		// only the process group of perf, not the processes of other tests
+		killProcess(cmd)
+		t.Fatalf("Time out: perf did not stop recording")
+	}
+
+	script := strings.TrimSuffix(path, ".data") + ".script"
+	if _, stderr, err := Exec(nil, fmt.Sprintf("sudo perf script --input=%s > %s", path, script)); err != nil {
+		t.Logf("Error writing perf script: %s: %s", err, stderr)
+	}
+
+	t.Logf("Wrote profile of %s to %s and %s", snap, path, script)
+	return path
+}
+
+// RequireProfileCaptured checks that a profile returned by ProfileSnap
+// contains samples, i.e. its perf script output isn't empty
+func RequireProfileCaptured(t *testing.T, path string) {
+	if path == "" {
+		t.Skipf("Profiling is disabled, set %s=true to enable", env.EnvProfile)
+	}
+
+	script := strings.TrimSuffix(path, ".data") + ".script"
+	info, err := os.Stat(script)
+	if err != nil {
+		t.Fatalf("Profile %s has no perf script output: %s", path, err)
+	}
+	if info.Size() == 0 {
+		t.Fatalf("Profile %s has no samples", path)
+	}
+}
@@ -0,0 +1,100 @@
+package utils
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// ControllerResult is the outcome of a command run by one of several
+// concurrent controllers
+type ControllerResult struct {
+	Controller int
+	Output     string
+	Err        error
+}
+
+// the cluster-specific Busy status of the Administrator Commissioning cluster,
+// e.g. "IM Error 0x00000602: Cluster-specific error: 0x02"
+var busyStatusExp = regexp.MustCompile(`Cluster-specific error: 0x0*2\b|\bBUSY\b`)
+
+// RequireCommissioningWindowRace has two controllers of chip-tool's fabric
+// open a commissioning window on a commissioned device at the same time and
+// checks that the device handles the race per spec: exactly one succeeds and
+// the other gets a Busy error. The window is revoked afterwards.
+//
+// The race is between two administrators of a commissioned device, since the
+// spec defines its outcome: the Administrator Commissioning cluster answers a
+// second open-commissioning-window with Busy while a window is open. Two
+// commissioners pairing the same commissionable device aren't raced, as the
+// loser's failure, e.g. a timeout or an error establishing PASE, depends on
+// timing rather than a status the spec requires.
+//
+// The second controller is a separate chip-tool process using a copy of
+// the storage, since chip-tool processes can't share the same storage.
+func RequireCommissioningWindowRace(t *testing.T, nodeID string) []ControllerResult {
+	const timeout = 2 * time.Minute
+
+	storage := ChipToolStorageDir + "/race-controller"
+	if _, stderr, err := ExecVerbose(t, fmt.Sprintf(
+		"sudo mkdir -p %s && sudo cp %s/chip_tool_*.ini %s/",
+		storage, ChipToolStorageDir, storage,
+	)); err != nil {
+		t.Fatalf("Error copying chip-tool storage: %s: %s", err, stderr)
+	}
+	t.Cleanup(func() {
+		ExecVerbose(t, "sudo rm -rf "+storage)
+		ChipTool(nil, fmt.Sprintf(
+			"administratorcommissioning revoke-commissioning %s 0 --timedInteractionTimeoutMs 10000",
+			nodeID,
+		))
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	storageOptions := []string{"", "--storage-directory " + storage}
+	results := make([]ControllerResult, len(storageOptions))
+	var wg sync.WaitGroup
+	for i, option := range storageOptions {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			stdout, stderr, err := ExecContextVerbose(nil, ctx, strings.TrimSpace(fmt.Sprintf(
+				"sudo chip-tool pairing open-commissioning-window %s 1 180 1000 %s %s",
+				nodeID,
+				DefaultDiscriminator,
+				option,
+			)))
+			results[i] = ControllerResult{Controller: i + 1, Output: stdout + stderr, Err: err}
+		}()
+	}
+	wg.Wait()
+
+	if ctx.Err() != nil {
+		t.Fatalf("Time out: controllers did not complete within %s", timeout)
+	}
+
+	var succeeded, busy []int
+	for _, r := range results {
+		switch {
+		case r.Err == nil:
+			succeeded = append(succeeded, r.Controller)
+		case busyStatusExp.MatchString(r.Output):
+			busy = append(busy, r.Controller)
+		default:
+			t.Errorf("Controller %d failed without a Busy error: %s", r.Controller, r.Err)
+		}
+	}
+	if len(succeeded) != 1 || len(busy) != 1 {
+		t.Fatalf("Expected one controller to open the commissioning window and one to get Busy, "+
+			"succeeded: %v, busy: %v", succeeded, busy)
+	}
+
+	t.Logf("Controller %d opened the commissioning window, controller %d got Busy", succeeded[0], busy[0])
+	return results
+}
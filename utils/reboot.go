@@ -0,0 +1,132 @@
+package utils
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/canonical/matter-snap-testing/env"
+)
+
+// file of the reboot checkpoint, relative to the working directory, which
+// has to persist across the reboot
+var rebootCheckpointFile = "reboot-checkpoint.json"
+
+// RebootCheckpoint is the state of a snap recorded before a reboot, to be
+// verified after it
+type RebootCheckpoint struct {
+	Snap     string    `json:"snap"`
+	Revision string    `json:"revision"`
+	NodeID   string    `json:"node-id"`
+	Ports    []string  `json:"ports"`
+	BootID   string    `json:"boot-id"`
+	Time     time.Time `json:"time"`
+}
+
+func writeRebootCheckpoint(path string, checkpoint RebootCheckpoint) error {
+	data, err := json.MarshalIndent(checkpoint, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0644)
+}
+
+func readRebootCheckpoint(path string) (checkpoint RebootCheckpoint, err error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return checkpoint, err
+	}
+	err = json.Unmarshal(data, &checkpoint)
+	return checkpoint, err
+}
+
+// rebootCheckpointPending returns whether a checkpoint awaits verification
+// after a reboot
+func rebootCheckpointPending() bool {
+	_, err := os.Stat(rebootCheckpointFile)
+	return err == nil
+}
+
+func bootID(t *testing.T) string {
+	id, _, _ := Exec(t, "cat /proc/sys/kernel/random/boot_id")
+	return strings.TrimSpace(id)
+}
+
+// SaveRebootCheckpoint records the state of a snap with a commissioned device
+// before a reboot: its revision, the node ID and the ports to wait for. It is
+// the first phase of a reboot test: CI reboots the host after the run and
+// runs the tests again with env.RebootResume set, where
+// RequireStateAfterReboot verifies the state. It is skipped in that run.
+//
+// While the checkpoint is pending, Suite keeps the snap installed.
+func SaveRebootCheckpoint(t *testing.T, snap, nodeID string, ports ...string) {
+	if env.RebootResume() {
+		t.Skipf("Resuming after reboot, unset %s to save a checkpoint", env.EnvRebootResume)
+	}
+
+	checkpoint := RebootCheckpoint{
+		Snap:     snap,
+		Revision: SnapRevision(t, snap),
+		NodeID:   nodeID,
+		Ports:    ports,
+		BootID:   bootID(t),
+		Time:     time.Now(),
+	}
+	if err := writeRebootCheckpoint(rebootCheckpointFile, checkpoint); err != nil {
+		t.Fatalf("Error writing reboot checkpoint: %s", err)
+	}
+	t.Logf("Wrote reboot checkpoint of %s to %s. Reboot and run again with %s=true to verify it.",
+		snap, rebootCheckpointFile, env.EnvRebootResume)
+}
+
+// ResumeAfterReboot returns the checkpoint written by SaveRebootCheckpoint,
+// after checking that the host rebooted since. The test is skipped unless
+// env.RebootResume is set.
+func ResumeAfterReboot(t *testing.T) RebootCheckpoint {
+	if !env.RebootResume() {
+		t.Skipf("Not resuming after reboot, set %s=true to resume", env.EnvRebootResume)
+	}
+
+	checkpoint, err := readRebootCheckpoint(rebootCheckpointFile)
+	if errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("No reboot checkpoint at %s, run SaveRebootCheckpoint before the reboot", rebootCheckpointFile)
+	} else if err != nil {
+		t.Fatalf("Error reading reboot checkpoint: %s", err)
+	}
+
+	if bootID(t) == checkpoint.BootID {
+		t.Fatalf("Host has not rebooted since the checkpoint of %s", checkpoint.Time.Format(time.RFC3339))
+	}
+	return checkpoint
+}
+
+// RequireStateAfterReboot verifies the checkpoint of SaveRebootCheckpoint
+// after the reboot: the snap has the same revision, its services are up and
+// listening and the device is still commissioned, i.e. it responds to a read
+// with the controller's stored fabric. The checkpoint is removed afterwards.
+func RequireStateAfterReboot(t *testing.T) {
+	checkpoint := ResumeAfterReboot(t)
+	snap := checkpoint.Snap
+
+	if revision := SnapRevision(t, snap); revision != checkpoint.Revision {
+		t.Fatalf("Snap %s has revision %s after reboot instead of %s", snap, revision, checkpoint.Revision)
+	}
+	if !SnapServicesActive(t, snap) {
+		t.Fatalf("Services of %s are not active after reboot", snap)
+	}
+	// Matter ports are UDP, which WaitServiceOnline can't connect to
+	for _, port := range checkpoint.Ports {
+		WaitPortListening(t, 60, port)
+	}
+
+	vendorID := ChipToolReadAttribute(t, checkpoint.NodeID, "basicinformation", "vendor-id", "0")
+	t.Logf("Node %s is still commissioned after reboot, vendor ID: %s", checkpoint.NodeID, vendorID)
+
+	if err := os.Remove(rebootCheckpointFile); err != nil {
+		t.Logf("Error removing reboot checkpoint: %s", err)
+	}
+	t.Logf("Snap %s passed the reboot checkpoint of %s", snap, checkpoint.Time.Format(time.RFC3339))
+}
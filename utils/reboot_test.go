@@ -0,0 +1,34 @@
+package utils
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRebootCheckpoint(t *testing.T) {
+	defaultFile := rebootCheckpointFile
+	t.Cleanup(func() {
+		rebootCheckpointFile = defaultFile
+	})
+	rebootCheckpointFile = filepath.Join(t.TempDir(), "reboot-checkpoint.json")
+	assert.False(t, rebootCheckpointPending())
+
+	checkpoint := RebootCheckpoint{
+		Snap:     "matter-all-clusters-app",
+		Revision: "12",
+		NodeID:   "110",
+		Ports:    []string{"5540"},
+		BootID:   "0f1e2d3c-4b5a-6978-8796-a5b4c3d2e1f0",
+		Time:     time.Date(2024, 4, 4, 10, 0, 0, 0, time.UTC),
+	}
+	require.NoError(t, writeRebootCheckpoint(rebootCheckpointFile, checkpoint))
+	assert.True(t, rebootCheckpointPending())
+
+	read, err := readRebootCheckpoint(rebootCheckpointFile)
+	require.NoError(t, err)
+	assert.Equal(t, checkpoint, read)
+}
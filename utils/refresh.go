@@ -2,7 +2,9 @@ package utils
 
 import (
 	"fmt"
+	"strconv"
 	"testing"
+	"time"
 
 	"github.com/canonical/matter-snap-testing/env"
 	"github.com/stretchr/testify/require"
@@ -26,7 +28,8 @@ func TestRefresh(t *testing.T, snapName string) {
 
 		t.Cleanup(func() {
 			SnapRemove(t, snapName)
-			SnapInstallFromStore(t, snapName, env.SnapChannel())
+			// reinstall the snap under test, which may be a local build
+			SnapInstallFromEnv(t, snapName)
 		})
 
 		originalVersion := SnapVersion(t, snapName)
@@ -50,7 +53,7 @@ func TestRefresh(t *testing.T, snapName string) {
 
 			// The command should not return error even if nothing is grepped, hence the "|| true"
 			stdout, stderr, _ := ExecVerbose(t,
-				fmt.Sprintf("sudo grep -RnI '%s/%s' /var/snap/%s/current || true",
+				fmt.Sprintf("sudo grep -RnIF '%s/%s' /var/snap/%s/current || true",
 					snapName, originalRevision, snapName))
 			require.Empty(t, stdout,
 				"The following files contain revision %s instead of %s or 'current' symlink: %s",
@@ -59,3 +62,176 @@ func TestRefresh(t *testing.T, snapName string) {
 		})
 	})
 }
+
+// TestRefreshCommissioning commissions the device of a snap, refreshes the
+// snap to the given channel and checks that the device is still commissioned
+// without commissioning it again
+func TestRefreshCommissioning(t *testing.T, snapName, nodeID, channel string) {
+	t.Run("refresh preserves commissioning", func(t *testing.T) {
+		t.Cleanup(func() {
+			ChipToolReset(t)
+		})
+
+		if err := ChipToolPairOnNetwork(t, nodeID, DefaultSetupPINCode); err != nil {
+			t.Fatalf("Error commissioning: %s", err)
+		}
+		fabrics := ChipToolReadFabricCount(t, nodeID)
+		originalRevision := SnapRevision(t, snapName)
+
+		SnapRefresh(t, snapName, channel)
+		t.Logf("Refreshed %s from revision %s to %s",
+			snapName, originalRevision, SnapRevision(t, snapName))
+
+		// wait for the restarted device to be operational again
+		WaitForMDNS(t, MDNSOperational, 60)
+		RequireFabricCount(t, nodeID, fabrics)
+	})
+}
+
+// TestRefreshConnection connects a plug, <snap>:<plug>, to a slot, refreshes
+// the snap to the given channel and checks that the connection persisted
+func TestRefreshConnection(t *testing.T, snapName, plug, slot, channel string) {
+	t.Run("refresh preserves connection of "+plug, func(t *testing.T) {
+		if err := SnapConnect(t, plug, slot); err != nil {
+			t.Fatalf("Error connecting: %s", err)
+		}
+		RequireConnected(t, plug, slot)
+
+		SnapRefresh(t, snapName, channel)
+		RequireConnected(t, plug, slot)
+	})
+}
+
+// TestRefreshEpoch refreshes the snap to the given channel, which must be on
+// a different epoch, and checks the snap's documented handling of its data
+// across the epoch boundary: kept, i.e. migrated, or reset.
+// The data is represented by a marker file in the snap's data directory.
+func TestRefreshEpoch(t *testing.T, snapName, channel string, expectDataReset bool) {
+	t.Run("refresh across epoch", func(t *testing.T) {
+		originalEpoch := SnapEpoch(t, snapName)
+
+		marker := fmt.Sprintf("/var/snap/%s/current/epoch-test-marker", snapName)
+		if _, stderr, err := ExecVerbose(t, "sudo touch "+marker); err != nil {
+			t.Fatalf("Error creating marker %s: %s: %s", marker, err, stderr)
+		}
+		t.Cleanup(func() {
+			ExecVerbose(t, "sudo rm -f "+marker)
+		})
+
+		SnapRefresh(t, snapName, channel)
+		refreshEpoch := SnapEpoch(t, snapName)
+		if refreshEpoch == originalEpoch {
+			t.Fatalf("Refresh to %s didn't change epoch %s", channel, originalEpoch)
+		}
+		t.Logf("Refreshed %s from epoch %s to %s", snapName, originalEpoch, refreshEpoch)
+
+		_, _, err := Exec(nil, "sudo test -e "+marker)
+		if kept := err == nil; kept == expectDataReset {
+			if expectDataReset {
+				t.Fatalf("Data was kept across epoch %s to %s, expected reset", originalEpoch, refreshEpoch)
+			}
+			t.Fatalf("Data was reset across epoch %s to %s, expected migration", originalEpoch, refreshEpoch)
+		}
+	})
+}
+
+// TestSwitchWhileHeld holds the refreshes of the snap and switches it to the
+// given channel, which only changes the tracked channel. It checks that the
+// service keeps listening on the port and the snap keeps its revision for the
+// given duration, i.e. neither the switch nor an auto-refresh falling in that
+// window replaces it. It doesn't trigger a refresh itself. The hold is then
+// released and the snap refreshed, after which the port must be back.
+func TestSwitchWhileHeld(t *testing.T, snapName, channel, port string, duration time.Duration) {
+	t.Run("channel switch while held doesn't disrupt service", func(t *testing.T) {
+		// refresh --hold with a snap name
+		RequireSnapdAtLeast(t, "2.58")
+
+		WaitPortListening(t, 60, port)
+		originalRevision := SnapRevision(t, snapName)
+
+		if _, stderr, err := ExecVerbose(t, "sudo snap refresh --hold "+snapName); err != nil {
+			t.Fatalf("Error holding refreshes: %s: %s", err, stderr)
+		}
+		t.Cleanup(func() {
+			ExecVerbose(t, "sudo snap refresh --unhold "+snapName)
+		})
+
+		// track the channel without refreshing, making a refresh available
+		ExecVerbose(t, fmt.Sprintf("sudo snap switch --channel=%s %s", channel, snapName))
+		ExecVerbose(t, "snap refresh --list || true")
+
+		deadline := time.Now().Add(duration)
+		for time.Now().Before(deadline) {
+			open := false
+			for _, l := range Listeners(t) {
+				open = open || l.Port == port
+			}
+			if !open {
+				t.Fatalf("Port %s closed while refreshes of %s were held", port, snapName)
+			}
+			time.Sleep(1 * time.Second)
+		}
+		if revision := SnapRevision(t, snapName); revision != originalRevision {
+			t.Fatalf("Held snap %s was refreshed from revision %s to %s", snapName, originalRevision, revision)
+		}
+
+		ExecVerbose(t, "sudo snap refresh --unhold "+snapName)
+		SnapRefresh(t, snapName, channel)
+		t.Logf("Refreshed %s after releasing the hold from revision %s to %s",
+			snapName, originalRevision, SnapRevision(t, snapName))
+		WaitPortListening(t, 60, port)
+	})
+}
+
+// RequireDowngradeBehavior installs a revision of a snap and refreshes it to a
+// lower revision. If success is expected, the downgrade must complete with the
+// snap's data preserved. Otherwise, it must fail cleanly: snapd keeps the
+// original revision and the services keep running.
+// The data is represented by a marker file in the snap's data directory.
+func RequireDowngradeBehavior(t *testing.T, snapName, fromRevision, toRevision string, expectSuccess bool) {
+	from, errFrom := strconv.Atoi(fromRevision)
+	to, errTo := strconv.Atoi(toRevision)
+	if errFrom != nil || errTo != nil || to >= from {
+		t.Fatalf("Invalid downgrade from revision %s to %s", fromRevision, toRevision)
+	}
+
+	if err := SnapInstallFromStore(t, snapName, fromRevision); err != nil {
+		t.Fatalf("Error installing revision %s: %s", fromRevision, err)
+	}
+
+	marker := fmt.Sprintf("/var/snap/%s/current/downgrade-test-marker", snapName)
+	if _, stderr, err := ExecVerbose(t, "sudo touch "+marker); err != nil {
+		t.Fatalf("Error creating marker %s: %s: %s", marker, err, stderr)
+	}
+	t.Cleanup(func() {
+		ExecVerbose(t, "sudo rm -f "+marker)
+	})
+
+	err := SnapRefreshRevision(t, snapName, toRevision)
+	revision := SnapRevision(t, snapName)
+
+	if expectSuccess {
+		if err != nil {
+			t.Fatalf("Downgrade from revision %s to %s failed: %s", fromRevision, toRevision, err)
+		}
+		if revision != toRevision {
+			t.Fatalf("Downgrade left revision %s instead of %s", revision, toRevision)
+		}
+		if _, _, err := Exec(nil, "sudo test -e "+marker); err != nil {
+			t.Fatalf("Data was lost on downgrade from revision %s to %s", fromRevision, toRevision)
+		}
+	} else {
+		if err == nil {
+			t.Fatalf("Downgrade from revision %s to %s succeeded unexpectedly", fromRevision, toRevision)
+		}
+		if revision != fromRevision {
+			t.Fatalf("Failed downgrade left revision %s instead of %s", revision, fromRevision)
+		}
+	}
+
+	if !SnapServicesActive(t, snapName) {
+		t.Fatalf("Services of %s are not active after downgrade to revision %s", snapName, toRevision)
+	}
+	t.Logf("Downgrade of %s from revision %s to %s behaved as expected, now at revision %s",
+		snapName, fromRevision, toRevision, revision)
+}
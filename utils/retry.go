@@ -0,0 +1,49 @@
+package utils
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+// RetryTest runs fn up to the given number of attempts and passes as soon as
+// an attempt succeeds. fn should report the outcome instead of failing the
+// test. The optional reset callback runs before every retry to isolate the
+// attempts from each other, e.g. by resetting chip-tool.
+func RetryTest(t *testing.T, attempts int, fn func(t *testing.T) bool, reset func(t *testing.T)) {
+	t.Helper()
+
+	for i := 1; i <= attempts; i++ {
+		if i > 1 && reset != nil {
+			reset(t)
+		}
+
+		if fn(t) {
+			t.Logf("Attempt %d/%d: passed", i, attempts)
+			return
+		}
+		t.Logf("Attempt %d/%d: failed", i, attempts)
+	}
+
+	t.Fatalf("All %d attempts failed", attempts)
+}
+
+// Eventually polls the condition at the given interval until it returns true,
+// failing the test if it doesn't within the timeout. The message describes
+// the awaited state, e.g. for a transition to complete.
+func Eventually(t *testing.T, timeout, interval time.Duration, condition func() bool, format string, args ...any) {
+	t.Helper()
+
+	msg := fmt.Sprintf(format, args...)
+	deadline := time.Now().Add(timeout)
+	for i := 1; ; i++ {
+		if condition() {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("Time out: waited %s for %s", timeout, msg)
+		}
+		t.Logf("Retry %d: Waiting for %s", i, msg)
+		time.Sleep(interval)
+	}
+}
@@ -0,0 +1,45 @@
+package utils
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRetryTest(t *testing.T) {
+
+	t.Run("pass on retry", func(t *testing.T) {
+		var attempts, resets int
+		RetryTest(t, 3,
+			func(t *testing.T) bool {
+				attempts++
+				return attempts == 2
+			},
+			func(t *testing.T) {
+				resets++
+			})
+		assert.Equal(t, 2, attempts)
+		assert.Equal(t, 1, resets)
+	})
+
+	t.Run("pass without reset", func(t *testing.T) {
+		var attempts int
+		RetryTest(t, 3,
+			func(t *testing.T) bool {
+				attempts++
+				return true
+			},
+			nil)
+		assert.Equal(t, 1, attempts)
+	})
+}
+
+func TestEventually(t *testing.T) {
+	var polls int
+	Eventually(t, time.Second, 10*time.Millisecond, func() bool {
+		polls++
+		return polls == 3
+	}, "third poll")
+	assert.Equal(t, 3, polls)
+}
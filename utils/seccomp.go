@@ -0,0 +1,150 @@
+package utils
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// SeccompDenial is a syscall of a snap's app blocked by its seccomp profile
+type SeccompDenial struct {
+	App     string // e.g. snap.matter-all-clusters-app.all-clusters-app
+	Command string
+	Syscall string // name, or number if unknown
+	// interface which allows the syscall, if known
+	Interface string
+	// what to do about the denial, given the snap's plugs
+	Suggestion string
+}
+
+// e.g. audit: type=1326 audit(1712236307.960:123): auid=4294967295 uid=0 gid=0
+// ses=4294967295 subj=snap.foo.bar pid=1234 comm="bar" exe="/snap/foo/x1/bin/bar"
+// sig=0 arch=c000003e syscall=165 compat=0 ip=0x7f0000000000 code=0x50000
+var seccompDenialExp = regexp.MustCompile(
+	`type=1326 .*?subj=(snap\.[^\s]+).*?comm="([^"]*)".*?arch=([0-9a-f]+) syscall=(\d+)`)
+
+// audit architectures and their syscall numbers which are allowed by an
+// interface, see interfaceOfSyscall
+var seccompArchs = map[string]struct {
+	name     string
+	syscalls map[int]string
+}{
+	"c000003e": {"x86_64", map[int]string{
+		43: "accept", 50: "listen", 101: "ptrace", 141: "setpriority",
+		144: "sched_setscheduler", 159: "adjtimex", 164: "settimeofday",
+		165: "mount", 166: "umount2", 169: "reboot", 175: "init_module",
+		176: "delete_module", 227: "clock_settime", 288: "accept4", 313: "finit_module",
+	}},
+	"c00000b7": {"aarch64", map[int]string{
+		39: "umount2", 40: "mount", 105: "init_module", 106: "delete_module",
+		112: "clock_settime", 117: "ptrace", 119: "sched_setscheduler",
+		140: "setpriority", 142: "reboot", 170: "settimeofday", 171: "adjtimex",
+		201: "listen", 202: "accept", 242: "accept4", 273: "finit_module",
+	}},
+}
+
+// interfaceOfSyscall maps syscalls blocked by the default seccomp profile to
+// the interface which allows them
+var interfaceOfSyscall = map[string]string{
+	"accept":             "network-bind",
+	"accept4":            "network-bind",
+	"listen":             "network-bind",
+	"mount":              "mount-control",
+	"umount2":            "mount-control",
+	"reboot":             "shutdown",
+	"sched_setscheduler": "process-control",
+	"setpriority":        "process-control",
+	"init_module":        "kernel-module-control",
+	"finit_module":       "kernel-module-control",
+	"delete_module":      "kernel-module-control",
+	"settimeofday":       "time-control",
+	"clock_settime":      "time-control",
+	"adjtimex":           "time-control",
+	"ptrace":             "system-trace",
+}
+
+// parseSeccompDenials returns the distinct denials of a snap's apps in the
+// kernel log
+func parseSeccompDenials(logs, snap string) (denials []SeccompDenial) {
+	seen := make(map[string]bool)
+	for _, match := range seccompDenialExp.FindAllStringSubmatch(logs, -1) {
+		app, command, arch, number := match[1], match[2], match[3], match[4]
+		if !strings.HasPrefix(app, "snap."+snap+".") {
+			continue
+		}
+
+		syscall := "syscall " + number
+		if n, err := strconv.Atoi(number); err == nil {
+			if name, found := seccompArchs[arch].syscalls[n]; found {
+				syscall = name
+			}
+		}
+
+		if key := app + " " + syscall; !seen[key] {
+			seen[key] = true
+			denials = append(denials, SeccompDenial{
+				App:       app,
+				Command:   command,
+				Syscall:   syscall,
+				Interface: interfaceOfSyscall[syscall],
+			})
+		}
+	}
+	return denials
+}
+
+// suggestInterfaces sets the suggestion of each denial, given the snap's
+// connections including the disconnected plugs
+func suggestInterfaces(snap string, denials []SeccompDenial, connections []SnapConnection) {
+	for i, d := range denials {
+		if d.Interface == "" {
+			denials[i].Suggestion = "no interface is known to allow it, check the app or request a policy change"
+			continue
+		}
+
+		denials[i].Suggestion = fmt.Sprintf("declare a plug of the %s interface", d.Interface)
+		for _, c := range connections {
+			plugSnap, _, _ := strings.Cut(c.Plug, ":")
+			if plugSnap != snap || c.Interface != d.Interface {
+				continue
+			}
+			if c.Connected() {
+				denials[i].Suggestion = fmt.Sprintf("%s is connected but doesn't allow the call, "+
+					"check the interface's attributes", c.Plug)
+				break
+			}
+			denials[i].Suggestion = fmt.Sprintf("connect %s", c.Plug)
+		}
+	}
+}
+
+// SnapSeccompDenials runs the operation, e.g. commissioning, and returns the
+// seccomp denials of the snap's apps logged by the kernel meanwhile, each
+// with the interface to connect or declare if known
+func SnapSeccompDenials(t *testing.T, snap string, operation func()) []SeccompDenial {
+	start := time.Now()
+	operation()
+
+	logs, _, _ := Exec(t, fmt.Sprintf(
+		"sudo journalctl --dmesg --no-pager --since \"%s\" --grep 'type=1326' || true",
+		start.Format("2006-01-02 15:04:05"),
+	))
+	denials := parseSeccompDenials(logs, snap)
+	suggestInterfaces(snap, denials, SnapConnections(t, snap))
+	return denials
+}
+
+// RequireNoSeccompDenials runs the operation and fails if any of the snap's
+// apps was denied a syscall, suggesting an interface for each
+func RequireNoSeccompDenials(t *testing.T, snap string, operation func()) {
+	denials := SnapSeccompDenials(t, snap, operation)
+	for _, d := range denials {
+		t.Errorf("Seccomp denied %s of %s (%s): %s", d.Syscall, d.App, d.Command, d.Suggestion)
+	}
+	if t.Failed() {
+		t.FailNow()
+	}
+}
@@ -0,0 +1,30 @@
+package utils
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSeccompDenials(t *testing.T) {
+	denials := parseSeccompDenials(`
+Apr 04 10:00:00 host kernel: audit: type=1326 audit(1712236307.960:123): auid=4294967295 uid=0 gid=0 ses=4294967295 subj=snap.matter-all-clusters-app.all-clusters-app pid=1234 comm="chip-all-cluste" exe="/snap/matter-all-clusters-app/x1/bin/chip-all-clusters-app" sig=0 arch=c000003e syscall=144 compat=0 ip=0x7f0000000000 code=0x50000
+Apr 04 10:00:01 host kernel: audit: type=1326 audit(1712236308.960:124): auid=4294967295 uid=0 gid=0 ses=4294967295 subj=snap.matter-all-clusters-app.all-clusters-app pid=1234 comm="chip-all-cluste" exe="/snap/matter-all-clusters-app/x1/bin/chip-all-clusters-app" sig=0 arch=c000003e syscall=144 compat=0 ip=0x7f0000000000 code=0x50000
+Apr 04 10:00:02 host kernel: audit: type=1326 audit(1712236309.960:125): auid=4294967295 uid=0 gid=0 ses=4294967295 subj=snap.matter-all-clusters-app.all-clusters-app pid=1234 comm="chip-all-cluste" exe="/snap/matter-all-clusters-app/x1/bin/chip-all-clusters-app" sig=0 arch=c00000b7 syscall=40 compat=0 ip=0x7f0000000000 code=0x50000
+Apr 04 10:00:03 host kernel: audit: type=1326 audit(1712236310.960:126): auid=4294967295 uid=0 gid=0 ses=4294967295 subj=snap.matter-all-clusters-app.all-clusters-app pid=1234 comm="chip-all-cluste" exe="/snap/matter-all-clusters-app/x1/bin/chip-all-clusters-app" sig=0 arch=c000003e syscall=999 compat=0 ip=0x7f0000000000 code=0x50000
+Apr 04 10:00:04 host kernel: audit: type=1326 audit(1712236311.960:127): auid=4294967295 uid=0 gid=0 ses=4294967295 subj=snap.chip-tool.chip-tool pid=1235 comm="chip-tool" exe="/snap/chip-tool/x1/bin/chip-tool" sig=0 arch=c000003e syscall=165 compat=0 ip=0x7f0000000000 code=0x50000
+`, "matter-all-clusters-app")
+	require.Len(t, denials, 3)
+	assert.Equal(t, "sched_setscheduler", denials[0].Syscall)
+	assert.Equal(t, "process-control", denials[0].Interface)
+	assert.Equal(t, "mount", denials[1].Syscall)
+	assert.Equal(t, "syscall 999", denials[2].Syscall)
+
+	suggestInterfaces("matter-all-clusters-app", denials, []SnapConnection{
+		{Interface: "process-control", Plug: "matter-all-clusters-app:process-control", Slot: "-"},
+	})
+	assert.Equal(t, "connect matter-all-clusters-app:process-control", denials[0].Suggestion)
+	assert.Equal(t, "declare a plug of the mount-control interface", denials[1].Suggestion)
+	assert.Contains(t, denials[2].Suggestion, "no interface is known")
+}
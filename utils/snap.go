@@ -2,11 +2,16 @@ package utils
 
 import (
 	"fmt"
+	"log"
 	"os"
+	"path/filepath"
+	"slices"
 	"strconv"
 	"strings"
 	"testing"
 	"time"
+
+	"github.com/canonical/matter-snap-testing/env"
 )
 
 // func SnapInstall(t *testing.T, name string) {
@@ -17,7 +22,13 @@ import (
 // 	}
 // }
 
-func SnapInstallFromStore(t *testing.T, name, channel string) error {
+// SnapInstallFromStore installs a snap from the store.
+// If the snap is already installed from the requested channel or revision,
+// the install is skipped. If it is installed from another one, it is
+// refreshed instead. Use RequireInstalledClean for a fresh install.
+// Additional flags such as --devmode can be passed as options. An installed
+// snap is refreshed with them if its confinement differs from theirs.
+func SnapInstallFromStore(t *testing.T, name, channel string, options ...string) error {
 
 	option := "--channel"
 	// install by revision if channel is a number
@@ -25,23 +36,221 @@ func SnapInstallFromStore(t *testing.T, name, channel string) error {
 		option = "--revision"
 	}
 
-	_, stderr, err := ExecVerbose(t, fmt.Sprintf(
-		"sudo snap install %s %s=%s",
+	command := "install"
+	if SnapInstalled(t, name) {
+		info := SnapInfo(t, name)
+		switch snapInstallAction(info, channel, options...) {
+		case snapInstallSkip:
+			msg := fmt.Sprintf("Snap %s is already installed from %s (%s), skipping install",
+				name, info.Tracking, info.Revision)
+			if t != nil {
+				t.Log(msg)
+			} else {
+				log.Print(msg)
+			}
+			return nil
+		case snapInstallRefresh:
+			// amend to also replace a local snap
+			command = "refresh --amend"
+		}
+	}
+
+	_, stderr, err := ExecVerbose(t, strings.TrimSpace(fmt.Sprintf(
+		"sudo snap %s %s %s=%s %s",
+		command,
 		name,
 		option,
 		channel,
+		strings.Join(options, " "),
+	)))
+
+	if err != nil {
+		return fmt.Errorf("%s: %s", err, stderr)
+	}
+	return nil
+}
+
+const (
+	snapInstallNew     = "install"
+	snapInstallSkip    = "skip"
+	snapInstallRefresh = "refresh"
+)
+
+// notes of snap info which tell whether an installed snap has the
+// confinement requested by an install option
+var snapConfinementNotes = map[string][2]string{
+	"--devmode":  {"devmode", "true"},
+	"--jailmode": {"jailmode", "true"},
+	"--classic":  {"confinement", "classic"},
+}
+
+// snapInstallAction decides how to install a snap from a channel or
+// revision with options, given the details of the existing installation if any
+func snapInstallAction(info SnapInfoResult, channel string, options ...string) string {
+	if info.Revision == "" {
+		return snapInstallNew
+	}
+
+	for _, option := range options {
+		if note, found := snapConfinementNotes[option]; found && info.Notes[note[0]] != note[1] {
+			return snapInstallRefresh
+		}
+	}
+
+	if _, err := strconv.Atoi(channel); err == nil {
+		if info.Revision == channel {
+			return snapInstallSkip
+		}
+		return snapInstallRefresh
+	}
+
+	// a channel without a track refers to the latest track
+	if !strings.Contains(channel, "/") {
+		channel = "latest/" + channel
+	}
+	// a local snap doesn't track any channel
+	if info.Tracking == channel {
+		return snapInstallSkip
+	}
+	return snapInstallRefresh
+}
+
+// RequireInstalledClean removes any existing installation of a snap,
+// including its data, and installs it from the source set via environment
+// variables, for hermetic runs
+func RequireInstalledClean(t *testing.T, name string, options ...string) {
+	if SnapInstalled(t, name) {
+		t.Logf("Removing existing installation of %s", name)
+		SnapRemove(t, name)
+	}
+	if err := SnapInstallFromEnv(t, name, options...); err != nil {
+		t.Fatalf("Error installing %s: %s", name, err)
+	}
+}
+
+// SnapInstallFromFile installs a local snap.
+// If the snap's assertions are next to it, e.g. foo_1.assert for foo_1.snap
+// as downloaded by `snap download`, they are acknowledged and the snap is
+// installed verified instead of with --dangerous. See SnapAck.
+// Additional flags such as --devmode can be passed as options.
+func SnapInstallFromFile(t *testing.T, path string, options ...string) error {
+	dangerous := "--dangerous"
+	assertionFile := strings.TrimSuffix(path, ".snap") + ".assert"
+	if _, err := os.Stat(assertionFile); err == nil {
+		if err := SnapAck(t, assertionFile); err != nil {
+			return err
+		}
+		dangerous = ""
+	}
+
+	_, stderr, err := ExecVerbose(t, strings.Join(strings.Fields(fmt.Sprintf(
+		"sudo snap install %s %s %s",
+		dangerous,
+		path,
+		strings.Join(options, " "),
+	)), " "))
+	if err != nil {
+		return fmt.Errorf("%s: %s", err, stderr)
+	}
+	return nil
+}
+
+// SnapAck adds the assertions of a file to the system assertion database,
+// e.g. the snap declaration and revision of a snap signed by a brand account
+func SnapAck(t *testing.T, assertionFile string) error {
+	_, stderr, err := ExecVerbose(t, fmt.Sprintf(
+		"sudo snap ack %s",
+		assertionFile,
 	))
+	if err != nil {
+		return fmt.Errorf("%s: %s", err, stderr)
+	}
+	return nil
+}
+
+// SnapEnableParallelInstances enables installing a snap more than once,
+// under instance names of the form <snap>_<key>
+func SnapEnableParallelInstances(t *testing.T) error {
+	// avoid a system configuration change if already enabled
+	out, _, _ := Exec(t, "snap get system experimental.parallel-instances || true")
+	if strings.TrimSpace(out) == "true" {
+		return nil
+	}
 
+	_, stderr, err := ExecVerbose(t,
+		"sudo snap set system experimental.parallel-instances=true")
 	if err != nil {
 		return fmt.Errorf("%s: %s", err, stderr)
 	}
 	return nil
 }
 
-func SnapInstallFromFile(t *testing.T, path string) error {
+// SnapInstanceName returns the name of a parallel instance of a snap,
+// <snap>_<key>, or the snap name if the instance key is empty.
+// Instance names can be used wherever helpers accept a snap name.
+func SnapInstanceName(name, key string) string {
+	if key == "" {
+		return name
+	}
+	return name + "_" + key
+}
+
+// SplitSnapInstanceName returns the snap name and instance key of an instance name
+func SplitSnapInstanceName(instance string) (name, key string) {
+	name, key, _ = strings.Cut(instance, "_")
+	return name, key
+}
+
+// SnapInstallInstanceFromStore installs a parallel instance of a snap from
+// the store, enabling parallel instances if needed
+func SnapInstallInstanceFromStore(t *testing.T, name, key, channel string, options ...string) error {
+	if key == "" {
+		return SnapInstallFromStore(t, name, channel, options...)
+	}
+	if err := SnapEnableParallelInstances(t); err != nil {
+		return err
+	}
+	return SnapInstallFromStore(t, SnapInstanceName(name, key), channel, options...)
+}
+
+// SnapInstallInstanceFromFile installs a local snap as a parallel instance,
+// enabling parallel instances if needed
+func SnapInstallInstanceFromFile(t *testing.T, path, name, key string, options ...string) error {
+	if key == "" {
+		return SnapInstallFromFile(t, path, options...)
+	}
+	if err := SnapEnableParallelInstances(t); err != nil {
+		return err
+	}
+	return SnapInstallFromFile(t, path, append([]string{"--name", SnapInstanceName(name, key)}, options...)...)
+}
+
+// SnapInstallFromEnv installs a snap, or a parallel instance given its
+// instance name, from the source set via environment variables.
+// See env.SnapInstallSource for the precedence of a local snap over a channel.
+func SnapInstallFromEnv(t *testing.T, name string, options ...string) error {
+	snapName, key := SplitSnapInstanceName(name)
+	path, channel := env.SnapInstallSource()
+	if path != "" {
+		return SnapInstallInstanceFromFile(t, path, snapName, key, options...)
+	}
+	if err := SnapUseStoreProxy(t); err != nil {
+		return err
+	}
+	return SnapInstallInstanceFromStore(t, snapName, key, channel, options...)
+}
+
+// SnapUseStoreProxy configures snapd to install snaps via the store proxy
+// set via environment variable, if any
+func SnapUseStoreProxy(t *testing.T) error {
+	proxy := env.SnapStoreProxy()
+	if proxy == "" || SnapGet(t, "system", "proxy.store") == proxy {
+		return nil
+	}
+
 	_, stderr, err := ExecVerbose(t, fmt.Sprintf(
-		"sudo snap install --dangerous %s",
-		path,
+		"sudo snap set system proxy.store=%s",
+		proxy,
 	))
 	if err != nil {
 		return fmt.Errorf("%s: %s", err, stderr)
@@ -49,6 +258,23 @@ func SnapInstallFromFile(t *testing.T, path string) error {
 	return nil
 }
 
+// RequireFromStoreProxy checks that snapd is configured with the store proxy
+// set via environment variable and that the installed snap has the expected
+// publisher, e.g. "Canonical IoT Labs (canonical-iot-labs)"
+func RequireFromStoreProxy(t *testing.T, name, expectedPublisher string) {
+	proxy := env.SnapStoreProxy()
+	if proxy == "" {
+		t.Skipf("No store proxy set via %s", env.EnvSnapStoreProxy)
+	}
+
+	if configured := SnapGet(t, "system", "proxy.store"); configured != proxy {
+		t.Fatalf("snapd uses store proxy '%s' instead of '%s'", configured, proxy)
+	}
+	if publisher := SnapInfo(t, name).Publisher; publisher != expectedPublisher {
+		t.Fatalf("Snap %s is published by '%s' instead of '%s'", name, publisher, expectedPublisher)
+	}
+}
+
 func SnapInstalled(t *testing.T, name string) bool {
 	out, _, _ := ExecVerbose(t, fmt.Sprintf(
 		"snap list %s || true",
@@ -94,6 +320,62 @@ func SnapConnectSecretstoreToken(t *testing.T, snap string) error {
 		snap+":edgex-secretstore-token")
 }
 
+// SnapConnection is a row of the `snap connections` output
+type SnapConnection struct {
+	Interface string
+	Plug      string // <snap>:<plug>
+	Slot      string // <snap>:<slot>, :<slot> for system slots, or - if disconnected
+	Notes     string
+}
+
+// Connected reports whether the plug is connected to a slot
+func (c SnapConnection) Connected() bool {
+	return c.Slot != "-"
+}
+
+// SlotSnap returns the name of the snap providing the slot.
+// It is empty for system slots and disconnected plugs.
+func (c SnapConnection) SlotSnap() string {
+	snap, _, _ := strings.Cut(c.Slot, ":")
+	if snap == "-" {
+		return ""
+	}
+	return snap
+}
+
+// SystemSlot reports whether the plug is connected to a slot of the system,
+// e.g. :bluez, rather than a slot provided by a snap, e.g. bluez:service
+func (c SnapConnection) SystemSlot() bool {
+	return strings.HasPrefix(c.Slot, ":")
+}
+
+// SnapConnections returns the connections of a snap's plugs and slots
+func SnapConnections(t *testing.T, name string) []SnapConnection {
+	out, _, _ := ExecVerbose(t, fmt.Sprintf(
+		"snap connections %s",
+		name,
+	))
+	return parseSnapConnections(out)
+}
+
+func parseSnapConnections(out string) (connections []SnapConnection) {
+	lines := strings.Split(strings.TrimSpace(out), "\n")
+	for i, line := range lines {
+		fields := strings.Fields(line)
+		// skip the header and malformed lines
+		if i == 0 || len(fields) != 4 {
+			continue
+		}
+		connections = append(connections, SnapConnection{
+			Interface: fields[0],
+			Plug:      fields[1],
+			Slot:      fields[2],
+			Notes:     fields[3],
+		})
+	}
+	return connections
+}
+
 func SnapDisconnect(t *testing.T, plug, slot string) {
 	ExecVerbose(t, fmt.Sprintf(
 		"sudo snap disconnect %s %s",
@@ -118,21 +400,30 @@ func SnapRevision(t *testing.T, name string) string {
 }
 
 func snapJournalCommand(start time.Time, name string) string {
+	// exclude the logs of parallel instances of the snap, <name>_<key>
+	exclude := ""
+	if _, key := SplitSnapInstanceName(name); key == "" {
+		exclude = fmt.Sprintf(" | grep -vF \"%s_\"", name)
+	}
+
+	// Match the name literally, as the dot of <snap>.<app> would match any
+	// character in a pattern.
 	// The command should not return error even if nothing is grepped, hence the "|| true"
-	return fmt.Sprintf("sudo journalctl --since \"%s\" --no-pager | grep \"%s\"|| true",
+	return fmt.Sprintf("sudo journalctl --since \"%s\" --no-pager | grep -F \"%s\"%s || true",
 		start.Format("2006-01-02 15:04:05"),
-		name)
+		name,
+		exclude)
 }
 
 func SnapDumpLogs(t *testing.T, start time.Time, snapName string) {
-	logFileName := logFileName(t, snapName)
-
-	ExecVerbose(t, fmt.Sprintf("(%s) > %s",
-		snapJournalCommand(start, snapName),
-		logFileName))
+	logFileName, err := WriteLogFile(t, snapName, SnapLogs(t, start, snapName))
+	if err != nil {
+		fmt.Printf("Error writing snap logs to %s: %s\n", logFileName, err)
+		return
+	}
 
-	wd, _ := os.Getwd()
-	fmt.Printf("Wrote snap logs to %s/%s\n", wd, logFileName)
+	path, _ := filepath.Abs(logFileName)
+	fmt.Printf("Wrote snap logs to %s\n", path)
 }
 
 func SnapLogs(t *testing.T, start time.Time, name string) string {
@@ -149,6 +440,51 @@ func SnapSet(t *testing.T, name, key, value string) {
 	))
 }
 
+// SnapGet returns the value of a snap option, or an empty string if unset
+func SnapGet(t *testing.T, name, key string) string {
+	out, _, _ := Exec(t, fmt.Sprintf(
+		"sudo snap get %s %s 2>/dev/null || true",
+		name,
+		key,
+	))
+	return strings.TrimSpace(out)
+}
+
+// RequireHookRan checks that snapd ran the snap's hook successfully since
+// the given time, e.g. the configure hook after a `snap set`, i.e. a change
+// spawned since then has a done task of the hook
+func RequireHookRan(t *testing.T, name, hook string, since time.Time) {
+	summary := fmt.Sprintf(`Run %s hook of "%s" snap`, hook, name)
+
+	changes := SnapChanges(t)
+	for i := len(changes) - 1; i >= 0; i-- {
+		change := changes[i]
+		if !spawnedSince(change.Spawn, since) {
+			continue
+		}
+		tasks, logs := SnapChangeTasks(t, change.ID)
+		for _, task := range tasks {
+			// e.g. the install hook's task is "Run install hook of "foo" snap if present"
+			if !strings.HasPrefix(task.Summary, summary) || !spawnedSince(task.Spawn, since) {
+				continue
+			}
+			if task.Status != "Done" {
+				t.Fatalf("Task of change %s is %s instead of Done: %s\n%s", change.ID, task.Status, task.Summary, logs)
+			}
+			t.Logf("Found %s hook run in change %s: %s", hook, change.ID, change.Summary)
+			return
+		}
+	}
+	t.Fatalf("Found no %s hook run of %s since %s", hook, name, since.Format(time.RFC3339))
+}
+
+// spawnedSince returns whether a snapd change or task was spawned since the
+// given time. The spawn times of snap commands only have whole seconds.
+func spawnedSince(spawn string, since time.Time) bool {
+	spawned, err := time.Parse(time.RFC3339, spawn)
+	return err == nil && !spawned.Before(since.Truncate(time.Second))
+}
+
 func SnapUnset(t *testing.T, name string, keys ...string) {
 	ExecVerbose(t, fmt.Sprintf(
 		"sudo snap unset %s %s",
@@ -175,6 +511,28 @@ func SnapStop(t *testing.T, names ...string) {
 	}
 }
 
+// SnapStartUser starts user daemons of snaps for the current user, via
+// `snap start --user`. User daemons run in the user's systemd instance
+// instead of as system services.
+func SnapStartUser(t *testing.T, names ...string) {
+	for _, name := range names {
+		ExecVerbose(t, fmt.Sprintf(
+			"snap start --user %s",
+			name,
+		))
+	}
+}
+
+// SnapStopUser stops user daemons of snaps for the current user
+func SnapStopUser(t *testing.T, names ...string) {
+	for _, name := range names {
+		ExecVerbose(t, fmt.Sprintf(
+			"snap stop --user %s",
+			name,
+		))
+	}
+}
+
 func SnapRestart(t *testing.T, names ...string) {
 	for _, name := range names {
 		ExecVerbose(t, fmt.Sprintf(
@@ -187,6 +545,21 @@ func SnapRestart(t *testing.T, names ...string) {
 	time.Sleep(1 * time.Second)
 }
 
+// SnapRun runs an app of a snap through snapd's launcher, with the snap's
+// runtime environment and confinement
+func SnapRun(t *testing.T, name, app string, args ...string) (stdout, stderr string, err error) {
+	command := name
+	// the app named after the snap is run by the snap name alone
+	if app != "" && app != name {
+		command += "." + app
+	}
+	return ExecVerbose(t, strings.TrimSpace(fmt.Sprintf(
+		"sudo snap run %s %s",
+		command,
+		strings.Join(args, " "),
+	)))
+}
+
 func SnapRefresh(t *testing.T, name, channel string) {
 	ExecVerbose(t, fmt.Sprintf(
 		"sudo snap refresh %s --channel=%s --amend",
@@ -195,6 +568,31 @@ func SnapRefresh(t *testing.T, name, channel string) {
 	))
 }
 
+// SnapRefreshRevision refreshes a snap to the given revision, which may be
+// lower than the installed one
+func SnapRefreshRevision(t *testing.T, name, revision string) error {
+	_, stderr, err := ExecVerbose(nil, fmt.Sprintf(
+		"sudo snap refresh %s --revision=%s --amend",
+		name,
+		revision,
+	))
+	if err != nil {
+		return fmt.Errorf("%s: %s", err, stderr)
+	}
+	return nil
+}
+
+// SnapPIDs returns the IDs of the processes running in the snap's cgroups,
+// i.e. its services and apps
+func SnapPIDs(t *testing.T, name string) []string {
+	// The command should not return error even if nothing is grepped, hence the "|| true"
+	out, _, _ := Exec(t, fmt.Sprintf(
+		"ps -e -o pid=,cgroup= | grep -F '/snap.%s.' | awk '{print $1}' || true",
+		name,
+	))
+	return strings.Fields(out)
+}
+
 func SnapServicesEnabled(t *testing.T, name string) bool {
 	out, _, _ := ExecVerbose(t, fmt.Sprintf(
 		"snap services %s | awk 'FNR == 2 {print $2}'",
@@ -210,3 +608,477 @@ func SnapServicesActive(t *testing.T, name string) bool {
 	))
 	return strings.TrimSpace(out) == "active"
 }
+
+// SnapUserServicesActive checks whether the user daemons of a snap, or a
+// single one given as <snap>.<app>, are active for the current user
+func SnapUserServicesActive(t *testing.T, name string) bool {
+	out, _, _ := ExecVerbose(t, fmt.Sprintf(
+		"systemctl --user is-active 'snap.%s.*' || true",
+		name,
+	))
+	states := strings.Fields(out)
+	return len(states) != 0 && !slices.ContainsFunc(states, func(s string) bool {
+		return s != "active"
+	})
+}
+
+// Scopes of snap daemons
+const (
+	DaemonScopeSystem = "system"
+	DaemonScopeUser   = "user"
+)
+
+// SnapDaemonScopes returns the scope of each service of a snap, by
+// <snap>.<app>, i.e. whether it runs as a system or user daemon
+func SnapDaemonScopes(t *testing.T, name string) map[string]string {
+	out, _, _ := Exec(t, fmt.Sprintf(
+		"snap services %s",
+		name,
+	))
+	return parseDaemonScopes(out)
+}
+
+// parseDaemonScopes parses the scopes of the services from the notes of
+// `snap services`, which mark user daemons with "user":
+//
+//	Service                                         Startup  Current  Notes
+//	matter-all-clusters-app.all-clusters-app        enabled  active   -
+//	matter-all-clusters-app.agent                   enabled  -        user
+func parseDaemonScopes(out string) map[string]string {
+	scopes := make(map[string]string)
+	for _, fields := range snapTableRows(out, 4) {
+		scopes[fields[0]] = DaemonScopeSystem
+		if slices.Contains(strings.Split(fields[3], ","), "user") {
+			scopes[fields[0]] = DaemonScopeUser
+		}
+	}
+	return scopes
+}
+
+// RequireDaemonScope checks whether a snap's service, e.g. "agent", runs as
+// a system or user daemon, i.e. DaemonScopeSystem or DaemonScopeUser
+func RequireDaemonScope(t *testing.T, snap, service, expected string) {
+	name := snap + "." + service
+	scope, found := SnapDaemonScopes(t, snap)[name]
+	if !found {
+		t.Fatalf("Snap %s has no service %s", snap, service)
+	}
+	if scope != expected {
+		t.Fatalf("Service %s is a %s daemon instead of a %s daemon", name, scope, expected)
+	}
+}
+
+// SnapModel returns the brand and model of the device's model assertion
+func SnapModel(t *testing.T) (brand, model string) {
+	out, _, _ := ExecVerbose(t, "snap model")
+	return parseSnapModel(out)
+}
+
+func parseSnapModel(out string) (brand, model string) {
+	for _, line := range strings.Split(out, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 2 {
+			continue
+		}
+		switch fields[0] {
+		case "brand":
+			// drop the validation mark, e.g. canonical✓
+			brand = strings.TrimRight(fields[1], "✓*")
+		case "model":
+			model = fields[1]
+		}
+	}
+	return brand, model
+}
+
+// RequireModel checks that the tests run on a device with the expected model
+func RequireModel(t *testing.T, expectedModel string) {
+	_, model := SnapModel(t)
+	if model != expectedModel {
+		t.Fatalf("Device model is '%s' instead of '%s'", model, expectedModel)
+	}
+}
+
+// SnapInfoResult holds the details of an installed snap, reported by `snap info`
+type SnapInfoResult struct {
+	Name      string
+	Publisher string
+	Tracking  string
+	Version   string
+	Revision  string
+	Base      string
+	// e.g. confinement: strict, devmode: false
+	Notes map[string]string
+}
+
+// SnapInfo returns the details of an installed snap
+func SnapInfo(t *testing.T, name string) SnapInfoResult {
+	out, _, _ := Exec(t, fmt.Sprintf(
+		"snap info --verbose %s",
+		name,
+	))
+	return parseSnapInfo(out)
+}
+
+func parseSnapInfo(out string) SnapInfoResult {
+	info := SnapInfoResult{Notes: make(map[string]string)}
+
+	var section string
+	for _, line := range strings.Split(out, "\n") {
+		key, value, found := strings.Cut(line, ":")
+		if !found {
+			continue
+		}
+		value = strings.TrimSpace(value)
+
+		// indented lines belong to the previous section
+		if strings.HasPrefix(line, " ") {
+			if section == "notes" {
+				info.Notes[strings.TrimSpace(key)] = value
+			}
+			continue
+		}
+		section = key
+
+		switch key {
+		case "name":
+			info.Name = value
+		case "publisher":
+			info.Publisher = value
+		case "tracking":
+			info.Tracking = value
+		case "base":
+			info.Base = value
+		case "installed":
+			// <version> (<revision>) <size> <flags>
+			fields := strings.Fields(value)
+			if len(fields) >= 2 {
+				info.Version = fields[0]
+				info.Revision = strings.Trim(fields[1], "()")
+			}
+		}
+	}
+	return info
+}
+
+// RequireBase checks that the snap is built on the expected base, e.g. core22
+func RequireBase(t *testing.T, name, expected string) {
+	info := SnapInfo(t, name)
+	t.Logf("Snap %s %s (%s) has base %s and notes %v",
+		name, info.Version, info.Revision, info.Base, info.Notes)
+	if info.Base != expected {
+		t.Fatalf("Snap %s has base '%s' instead of '%s'", name, info.Base, expected)
+	}
+}
+
+// SnapEpoch returns the epoch of an installed snap from its snap.yaml,
+// e.g. 0, 1* or {read: [1, 2], write: [2]}
+func SnapEpoch(t *testing.T, name string) string {
+	out, _, _ := Exec(t, fmt.Sprintf(
+		"cat /snap/%s/current/meta/snap.yaml",
+		name,
+	))
+	return parseSnapEpoch(out)
+}
+
+func parseSnapEpoch(snapYaml string) string {
+	lines := strings.Split(snapYaml, "\n")
+	for i, line := range lines {
+		value, found := strings.CutPrefix(line, "epoch:")
+		if !found {
+			continue
+		}
+		if value = strings.TrimSpace(value); value != "" {
+			return strings.Trim(value, `"'`)
+		}
+
+		// block mapping of read and write epochs
+		var fields []string
+		for _, l := range lines[i+1:] {
+			if !strings.HasPrefix(l, " ") {
+				break
+			}
+			fields = append(fields, strings.TrimSpace(l))
+		}
+		return "{" + strings.Join(fields, ", ") + "}"
+	}
+	// the default epoch
+	return "0"
+}
+
+// RequireEpoch checks that the snap has the expected epoch
+func RequireEpoch(t *testing.T, name, expected string) {
+	if epoch := SnapEpoch(t, name); epoch != expected {
+		t.Fatalf("Snap %s has epoch '%s' instead of '%s'", name, epoch, expected)
+	}
+}
+
+// SnapChannelRelease is a release of a snap in a store channel
+type SnapChannelRelease struct {
+	Channel  string // e.g. latest/edge
+	Version  string
+	Revision string
+	// e.g. classic or devmode, or - for strict confinement
+	Notes string
+}
+
+// SnapStoreInfo returns the releases of a snap in the store's open channels,
+// without installing it
+func SnapStoreInfo(t *testing.T, name string) []SnapChannelRelease {
+	out, _, _ := Exec(t, fmt.Sprintf(
+		"snap info --unicode=never %s",
+		name,
+	))
+	return parseSnapChannels(out)
+}
+
+// parseSnapChannels parses the channels of `snap info`. Channels which follow
+// the one above, ^, get its release and closed channels, --, are left out.
+//
+//	channels:
+//	  latest/stable:    1.0.0 2024-01-10 (10) 12MB -
+//	  latest/candidate: ^
+//	  latest/edge:      1.1.0 2024-02-01 (12) 12MB devmode
+//	  1.0/stable:       --
+func parseSnapChannels(out string) (releases []SnapChannelRelease) {
+	inChannels := false
+	var previous *SnapChannelRelease
+	for _, line := range strings.Split(out, "\n") {
+		if !strings.HasPrefix(line, " ") {
+			inChannels = line == "channels:"
+			continue
+		}
+		if !inChannels {
+			continue
+		}
+
+		channel, value, found := strings.Cut(strings.TrimSpace(line), ":")
+		if !found {
+			continue
+		}
+		fields := strings.Fields(value)
+
+		switch {
+		case len(fields) == 1 && fields[0] == "^":
+			if previous == nil {
+				// follows a closed channel
+				continue
+			}
+			release := *previous
+			release.Channel = channel
+			releases = append(releases, release)
+		case len(fields) >= 5:
+			// <version> <date> (<revision>) <size> <notes>
+			releases = append(releases, SnapChannelRelease{
+				Channel:  channel,
+				Version:  fields[0],
+				Revision: strings.Trim(fields[2], "()"),
+				Notes:    fields[len(fields)-1],
+			})
+		default:
+			// closed channel, which doesn't pass its release on
+			previous = nil
+			continue
+		}
+		previous = &releases[len(releases)-1]
+	}
+	return releases
+}
+
+// RequireChannelRelease checks that the store has a release of the snap in
+// the channel, e.g. before installing from it, and returns the release.
+// A channel without a track, e.g. edge, refers to the latest track.
+func RequireChannelRelease(t *testing.T, name, channel string) SnapChannelRelease {
+	if !strings.Contains(channel, "/") {
+		channel = "latest/" + channel
+	}
+
+	releases := SnapStoreInfo(t, name)
+	for _, r := range releases {
+		if r.Channel == channel {
+			t.Logf("Channel %s of %s has version %s (%s), notes: %s",
+				channel, name, r.Version, r.Revision, r.Notes)
+			return r
+		}
+	}
+	t.Fatalf("Channel %s of %s is empty or closed, open channels: %v", channel, name, releases)
+	return SnapChannelRelease{}
+}
+
+// SnapChange is an operation recorded by snapd, e.g. an install
+type SnapChange struct {
+	ID     string
+	Status string // e.g. Done, Doing or Error
+	// times in RFC 3339, or - if not ready
+	Spawn, Ready string
+	Summary      string
+}
+
+// SnapTask is a step of a snapd change, e.g. "Mount snap"
+type SnapTask struct {
+	Status       string
+	Spawn, Ready string
+	Summary      string
+}
+
+// SnapChanges returns the recent changes of snapd, oldest first
+func SnapChanges(t *testing.T) []SnapChange {
+	out, _, _ := Exec(t, "snap changes --abs-time")
+	return parseSnapChanges(out)
+}
+
+// parseSnapChanges parses the output of `snap changes --abs-time`:
+//
+//	ID   Status  Spawn                 Ready                 Summary
+//	12   Done    2024-04-04T10:00:00Z  2024-04-04T10:00:05Z  Install "matter-all-clusters-app" snap
+func parseSnapChanges(out string) (changes []SnapChange) {
+	for _, fields := range snapTableRows(out, 5) {
+		changes = append(changes, SnapChange{
+			ID:      fields[0],
+			Status:  fields[1],
+			Spawn:   fields[2],
+			Ready:   fields[3],
+			Summary: fields[4],
+		})
+	}
+	return changes
+}
+
+// SnapChangeTasks returns the tasks of a snapd change and the task logs,
+// which include the reason of errors
+func SnapChangeTasks(t *testing.T, id string) (tasks []SnapTask, logs string) {
+	out, _, _ := Exec(t, fmt.Sprintf("snap change --abs-time %s", id))
+	return parseSnapChangeTasks(out)
+}
+
+// parseSnapChangeTasks parses the output of `snap change --abs-time`. The
+// task table is followed by the logs of tasks, after a separator line:
+//
+//	Status  Spawn                 Ready                 Summary
+//	Done    2024-04-04T10:00:00Z  2024-04-04T10:00:01Z  Prepare snap "foo" (12)
+//	Error   2024-04-04T10:00:00Z  2024-04-04T10:00:02Z  Start snap "foo" (12) services
+//
+//	......................................................................
+//	Start snap "foo" (12) services
+//
+//	2024-04-04T10:00:02Z ERROR ...
+func parseSnapChangeTasks(out string) (tasks []SnapTask, logs string) {
+	table, logs, _ := strings.Cut(out, "\n....")
+	for _, fields := range snapTableRows(table, 4) {
+		tasks = append(tasks, SnapTask{
+			Status:  fields[0],
+			Spawn:   fields[1],
+			Ready:   fields[2],
+			Summary: fields[3],
+		})
+	}
+	if logs != "" {
+		// drop the rest of the separator line
+		_, logs, _ = strings.Cut(logs, "\n")
+	}
+	return tasks, strings.TrimSpace(logs)
+}
+
+// snapTableRows splits the rows of a snap command's table, skipping the
+// header. The last of n columns takes the rest of the row.
+func snapTableRows(out string, n int) (rows [][]string) {
+	lines := strings.Split(strings.TrimSpace(out), "\n")
+	if len(lines) < 2 {
+		return nil
+	}
+	for _, line := range lines[1:] {
+		fields := strings.Fields(line)
+		if len(fields) < n {
+			continue
+		}
+		rows = append(rows, append(fields[:n-1:n-1], strings.Join(fields[n-1:], " ")))
+	}
+	return rows
+}
+
+// RequireLastChangeDone checks that the most recent snapd change succeeded,
+// e.g. after an install or refresh. Otherwise, it fails with the tasks which
+// didn't complete and their logs.
+func RequireLastChangeDone(t *testing.T) {
+	changes := SnapChanges(t)
+	if len(changes) == 0 {
+		t.Fatalf("snapd has no changes")
+	}
+
+	last := changes[len(changes)-1]
+	if last.Status == "Done" {
+		t.Logf("Last change %s is done: %s", last.ID, last.Summary)
+		return
+	}
+
+	tasks, logs := SnapChangeTasks(t, last.ID)
+	for _, task := range tasks {
+		if task.Status != "Done" {
+			t.Logf("Task of change %s is %s: %s", last.ID, task.Status, task.Summary)
+		}
+	}
+	t.Fatalf("Last change %s is %s instead of Done: %s\n%s", last.ID, last.Status, last.Summary, logs)
+}
+
+// SnapdVersion returns the version of snapd, e.g. 2.61.2
+func SnapdVersion(t *testing.T) string {
+	out, _, _ := Exec(t, "snap version")
+	return parseSnapdVersion(out)
+}
+
+func parseSnapdVersion(out string) string {
+	for _, line := range strings.Split(out, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 2 && fields[0] == "snapd" {
+			return fields[1]
+		}
+	}
+	return ""
+}
+
+// compareVersions compares the numeric components of two dotted versions,
+// ignoring suffixes such as +git123, and returns -1, 0 or 1
+func compareVersions(a, b string) int {
+	numbers := func(v string) (nums []int) {
+		for _, part := range strings.Split(v, ".") {
+			end := strings.IndexFunc(part, func(r rune) bool { return r < '0' || r > '9' })
+			if end == -1 {
+				end = len(part)
+			}
+			n, _ := strconv.Atoi(part[:end])
+			nums = append(nums, n)
+			// stop at the first component with a suffix
+			if end != len(part) {
+				break
+			}
+		}
+		return nums
+	}
+
+	numsA, numsB := numbers(a), numbers(b)
+	for i := 0; i < max(len(numsA), len(numsB)); i++ {
+		var x, y int
+		if i < len(numsA) {
+			x = numsA[i]
+		}
+		if i < len(numsB) {
+			y = numsB[i]
+		}
+		if x != y {
+			if x < y {
+				return -1
+			}
+			return 1
+		}
+	}
+	return 0
+}
+
+// RequireSnapdAtLeast skips the test if snapd is older than the given
+// version, for helpers which depend on newer snapd features
+func RequireSnapdAtLeast(t *testing.T, minVersion string) {
+	version := SnapdVersion(t)
+	if compareVersions(version, minVersion) < 0 {
+		t.Skipf("Requires snapd %s or newer, found %s", minVersion, version)
+	}
+}
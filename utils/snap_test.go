@@ -0,0 +1,272 @@
+package utils
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseSnapConnections(t *testing.T) {
+	connections := parseSnapConnections(`
+Interface          Plug                  Slot                Notes
+content[gpu-2404]  chip-tool:gpu-2404    mesa-2404:gpu-2404  -
+network            chip-tool:network     :network            -
+avahi-observe      chip-tool:avahi-observe  -                -
+`)
+	require.Len(t, connections, 3)
+
+	assert.Equal(t, "content[gpu-2404]", connections[0].Interface)
+	assert.Equal(t, "mesa-2404", connections[0].SlotSnap())
+	assert.True(t, connections[0].Connected())
+
+	assert.Equal(t, "", connections[1].SlotSnap())
+	assert.True(t, connections[1].Connected())
+	assert.True(t, connections[1].SystemSlot())
+	assert.False(t, connections[0].SystemSlot())
+
+	assert.Equal(t, "chip-tool:avahi-observe", connections[2].Plug)
+	assert.Equal(t, "", connections[2].SlotSnap())
+	assert.False(t, connections[2].Connected())
+}
+
+func TestSnapInstanceName(t *testing.T) {
+	assert.Equal(t, "chip-tool", SnapInstanceName("chip-tool", ""))
+	assert.Equal(t, "chip-tool_test", SnapInstanceName("chip-tool", "test"))
+
+	name, key := SplitSnapInstanceName("chip-tool_test")
+	assert.Equal(t, "chip-tool", name)
+	assert.Equal(t, "test", key)
+
+	name, key = SplitSnapInstanceName("chip-tool")
+	assert.Equal(t, "chip-tool", name)
+	assert.Empty(t, key)
+}
+
+func TestSnapJournalCommand(t *testing.T) {
+	start := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	assert.Equal(t,
+		`sudo journalctl --since "2024-01-02 03:04:05" --no-pager | grep -F "chip-tool" | grep -vF "chip-tool_" || true`,
+		snapJournalCommand(start, "chip-tool"))
+	assert.Equal(t,
+		`sudo journalctl --since "2024-01-02 03:04:05" --no-pager | grep -F "chip-tool_test" || true`,
+		snapJournalCommand(start, "chip-tool_test"))
+}
+
+func TestSpecialSnapNames(t *testing.T) {
+	start := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	t.Run("instance key", func(t *testing.T) {
+		instance := SnapInstanceName("matter-all-clusters-app", "rev2")
+		assert.Equal(t,
+			`sudo journalctl --since "2024-01-02 03:04:05" --no-pager | grep -F "matter-all-clusters-app_rev2" || true`,
+			snapJournalCommand(start, instance))
+		assert.Equal(t, "snap.matter-all-clusters-app_rev2.all-clusters-app.service",
+			SnapServiceUnit(instance, "all-clusters-app"))
+		assert.Equal(t, "snap.openthread-border-router_rev2.otbr-agent.service",
+			dependencyUnit("openthread-border-router_rev2.otbr-agent"))
+	})
+
+	t.Run("app", func(t *testing.T) {
+		// the dot must not match any character
+		assert.Equal(t,
+			`sudo journalctl --since "2024-01-02 03:04:05" --no-pager | grep -F "matter-all-clusters-app.all-clusters-app" | grep -vF "matter-all-clusters-app.all-clusters-app_" || true`,
+			snapJournalCommand(start, "matter-all-clusters-app.all-clusters-app"))
+	})
+
+	t.Run("log file names", func(t *testing.T) {
+		defaultDirectory := logDirectory
+		t.Cleanup(func() {
+			logDirectory = defaultDirectory
+		})
+		logDirectory = t.TempDir()
+
+		assert.Equal(t,
+			filepath.Join(logDirectory, "TestSpecialSnapNames-log_file_names-5869d2b7-matter-all-clusters-app_rev2.log"),
+			logFileName(t, "matter-all-clusters-app_rev2"))
+		assert.Equal(t,
+			filepath.Join(logDirectory, "TestSpecialSnapNames-log_file_names-5869d2b7-chip-tool-pairing-onnetwork-110-.log"),
+			logFileName(t, "chip-tool 'pairing onnetwork 110'"))
+		assert.Equal(t,
+			filepath.Join(logDirectory, "matter-all-clusters-app_rev2.log"),
+			logFileName(nil, "matter-all-clusters-app_rev2"))
+	})
+}
+
+func TestParseSnapModel(t *testing.T) {
+	brand, model := parseSnapModel(`brand   canonical✓
+model   ubuntu-core-22-pi-arm64
+serial  1234abcd
+`)
+	assert.Equal(t, "canonical", brand)
+	assert.Equal(t, "ubuntu-core-22-pi-arm64", model)
+}
+
+func TestParseSnapInfo(t *testing.T) {
+	info := parseSnapInfo(`name:      chip-tool
+summary:   Matter Controller
+publisher: Canonical IoT Labs (canonical-iot-labs)
+license:   Apache-2.0
+description: |
+  chip-tool: a Matter controller
+commands:
+  - chip-tool
+notes:
+  private:           false
+  confinement:       strict
+  devmode:           false
+base:         core22
+tracking:     latest/edge
+refresh-date: today at 10:00 UTC
+channels:
+  latest/stable:    1.1.0.1+snap 2023-10-01 (180) 30MB -
+installed:          1.2.0.1+snap            (220) 32MB -
+`)
+	assert.Equal(t, "chip-tool", info.Name)
+	assert.Equal(t, "Canonical IoT Labs (canonical-iot-labs)", info.Publisher)
+	assert.Equal(t, "latest/edge", info.Tracking)
+	assert.Equal(t, "core22", info.Base)
+	assert.Equal(t, "1.2.0.1+snap", info.Version)
+	assert.Equal(t, "220", info.Revision)
+	assert.Equal(t, "strict", info.Notes["confinement"])
+	assert.Equal(t, "false", info.Notes["devmode"])
+}
+
+func TestSnapdVersion(t *testing.T) {
+	assert.Equal(t, "2.61.2", parseSnapdVersion(`snap    2.61.2
+snapd   2.61.2
+series  16
+ubuntu  22.04
+kernel  5.15.0-91-generic
+`))
+
+	assert.Equal(t, 0, compareVersions("2.58", "2.58.0"))
+	assert.Equal(t, 1, compareVersions("2.61.2", "2.58"))
+	assert.Equal(t, -1, compareVersions("2.9", "2.58"))
+	assert.Equal(t, 1, compareVersions("2.62+git1234.abcd", "2.61.3"))
+}
+
+func TestParseSnapEpoch(t *testing.T) {
+	assert.Equal(t, "0", parseSnapEpoch("name: matter-pi-gpio-commander\nversion: 1.0\n"))
+	assert.Equal(t, "1*", parseSnapEpoch("name: test\nepoch: 1*\nbase: core22\n"))
+	assert.Equal(t, "2", parseSnapEpoch("name: test\nepoch: '2'\n"))
+	assert.Equal(t, "{read: [1, 2], write: [2]}", parseSnapEpoch(`name: test
+epoch:
+  read: [1, 2]
+  write: [2]
+base: core22
+`))
+}
+
+func TestParseSnapChannels(t *testing.T) {
+	releases := parseSnapChannels(`name:      matter-all-clusters-app
+summary:   Matter All Clusters App
+publisher: Canonical**
+store-url: https://snapcraft.io/matter-all-clusters-app
+license:   Apache-2.0
+description: |
+  This is a Matter All Clusters App.
+snap-id: Fs8y5hniLHTPkPZbVSSwnPvDfzj5x8CN
+channels:
+  latest/stable:    1.0.0 2024-01-10 (10) 12MB -
+  latest/candidate: ^
+  latest/beta:      --
+  latest/edge:      1.1.0+git 2024-02-01 (12) 12MB devmode
+  1.0/stable:       --
+  1.0/candidate:    ^
+`)
+	assert.Equal(t, []SnapChannelRelease{
+		{Channel: "latest/stable", Version: "1.0.0", Revision: "10", Notes: "-"},
+		{Channel: "latest/candidate", Version: "1.0.0", Revision: "10", Notes: "-"},
+		{Channel: "latest/edge", Version: "1.1.0+git", Revision: "12", Notes: "devmode"},
+	}, releases)
+
+	// follows a closed channel as the first release
+	releases = parseSnapChannels(`channels:
+  latest/stable:    --
+  latest/candidate: ^
+  latest/beta:      ^
+  latest/edge:      1.1.0 2024-02-01 (12) 12MB -
+`)
+	assert.Equal(t, []SnapChannelRelease{
+		{Channel: "latest/edge", Version: "1.1.0", Revision: "12", Notes: "-"},
+	}, releases)
+}
+
+func TestSnapInstallAction(t *testing.T) {
+	installed := SnapInfoResult{Tracking: "latest/edge", Revision: "120"}
+	local := SnapInfoResult{Revision: "x1"}
+
+	assert.Equal(t, snapInstallNew, snapInstallAction(SnapInfoResult{}, "latest/edge"))
+	assert.Equal(t, snapInstallSkip, snapInstallAction(installed, "latest/edge"))
+	assert.Equal(t, snapInstallSkip, snapInstallAction(installed, "edge"))
+	assert.Equal(t, snapInstallRefresh, snapInstallAction(installed, "latest/beta"))
+	assert.Equal(t, snapInstallSkip, snapInstallAction(installed, "120"))
+	assert.Equal(t, snapInstallRefresh, snapInstallAction(installed, "110"))
+	assert.Equal(t, snapInstallRefresh, snapInstallAction(local, "latest/edge"))
+
+	strict := SnapInfoResult{Tracking: "latest/edge", Revision: "120",
+		Notes: map[string]string{"confinement": "strict", "devmode": "false"}}
+	devmode := SnapInfoResult{Tracking: "latest/edge", Revision: "120",
+		Notes: map[string]string{"confinement": "strict", "devmode": "true"}}
+	assert.Equal(t, snapInstallRefresh, snapInstallAction(strict, "latest/edge", "--devmode"))
+	assert.Equal(t, snapInstallRefresh, snapInstallAction(strict, "120", "--devmode"))
+	assert.Equal(t, snapInstallRefresh, snapInstallAction(strict, "latest/edge", "--classic"))
+	assert.Equal(t, snapInstallSkip, snapInstallAction(devmode, "latest/edge", "--devmode"))
+	assert.Equal(t, snapInstallSkip, snapInstallAction(strict, "latest/edge", "--dangerous"))
+}
+
+func TestParseSnapChanges(t *testing.T) {
+	changes := parseSnapChanges(`ID   Status  Spawn                 Ready                 Summary
+11   Done    2024-04-04T09:00:00Z  2024-04-04T09:00:03Z  Initialize device
+12   Error   2024-04-04T10:00:00Z  2024-04-04T10:00:05Z  Install "matter-all-clusters-app" snap
+`)
+	require.Len(t, changes, 2)
+	assert.Equal(t, SnapChange{
+		ID:      "12",
+		Status:  "Error",
+		Spawn:   "2024-04-04T10:00:00Z",
+		Ready:   "2024-04-04T10:00:05Z",
+		Summary: `Install "matter-all-clusters-app" snap`,
+	}, changes[1])
+
+	tasks, logs := parseSnapChangeTasks(`Status  Spawn                 Ready                 Summary
+Done    2024-04-04T10:00:00Z  2024-04-04T10:00:01Z  Prepare snap "matter-all-clusters-app" (12)
+Error   2024-04-04T10:00:01Z  2024-04-04T10:00:05Z  Start snap "matter-all-clusters-app" (12) services
+
+......................................................................
+Start snap "matter-all-clusters-app" (12) services
+
+2024-04-04T10:00:05Z ERROR systemctl command [start snap.matter-all-clusters-app.all-clusters-app.service] failed with exit status 1
+`)
+	require.Len(t, tasks, 2)
+	assert.Equal(t, "Error", tasks[1].Status)
+	assert.Equal(t, `Start snap "matter-all-clusters-app" (12) services`, tasks[1].Summary)
+	assert.Regexp(t, `^Start snap "matter-all-clusters-app"`, logs)
+	assert.Contains(t, logs, "ERROR systemctl command")
+}
+
+func TestSpawnedSince(t *testing.T) {
+	since := time.Date(2024, 4, 4, 10, 0, 0, 500_000_000, time.UTC)
+	assert.True(t, spawnedSince("2024-04-04T10:00:01Z", since))
+	// within the same second
+	assert.True(t, spawnedSince("2024-04-04T10:00:00Z", since))
+	assert.True(t, spawnedSince("2024-04-04T12:00:00+02:00", since))
+	assert.False(t, spawnedSince("2024-04-04T09:59:59Z", since))
+	// not spawned or invalid
+	assert.False(t, spawnedSince("-", since))
+}
+
+func TestParseDaemonScopes(t *testing.T) {
+	scopes := parseDaemonScopes(`Service                                   Startup  Current  Notes
+matter-all-clusters-app.all-clusters-app  enabled  active   -
+matter-all-clusters-app.agent             enabled  -        user,dbus-activated
+`)
+	assert.Equal(t, map[string]string{
+		"matter-all-clusters-app.all-clusters-app": DaemonScopeSystem,
+		"matter-all-clusters-app.agent":            DaemonScopeUser,
+	}, scopes)
+}
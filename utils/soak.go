@@ -0,0 +1,52 @@
+package utils
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+// SoakTest runs the iteration, e.g. commission, control and decommission,
+// repeatedly until the duration elapses. chip-tool is reset after every
+// iteration. It writes a summary of the pass/fail counts and iteration
+// latencies to the log directory and fails if the failure rate, between 0
+// and 1, exceeds maxFailureRate.
+func SoakTest(t *testing.T, duration time.Duration, maxFailureRate float64, iteration func(t *testing.T) error) {
+	deadline := time.Now().Add(duration)
+
+	var passed, failed int
+	var latencies []time.Duration
+	for i := 1; time.Now().Before(deadline); i++ {
+		start := time.Now()
+		err := iteration(t)
+		latencies = append(latencies, time.Since(start))
+
+		if err != nil {
+			failed++
+			t.Logf("Iteration %d failed: %s", i, err)
+		} else {
+			passed++
+		}
+
+		if err := ChipToolReset(t); err != nil {
+			t.Logf("Error resetting chip-tool after iteration %d: %s", i, err)
+		}
+	}
+
+	total := passed + failed
+	if total == 0 {
+		t.Fatalf("No iterations completed within %s", duration)
+	}
+	failureRate := float64(failed) / float64(total)
+
+	summary := fmt.Sprintf("duration=%s iterations=%d passed=%d failed=%d failure_rate=%.3f latency: %s\n",
+		duration, total, passed, failed, failureRate, newLatencyStats(latencies))
+	t.Logf("Soak test: %s", summary)
+	if _, err := WriteLogFile(t, "soak-summary", summary); err != nil {
+		t.Logf("Error writing soak test summary: %s", err)
+	}
+
+	if failureRate > maxFailureRate {
+		t.Fatalf("Failure rate %.3f exceeds %.3f", failureRate, maxFailureRate)
+	}
+}
@@ -0,0 +1,104 @@
+package utils
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/canonical/matter-snap-testing/env"
+)
+
+// SuiteConfig describes the snap under test of a suite
+type SuiteConfig struct {
+	Snap string
+	// install options, e.g. --devmode
+	InstallOptions []string
+	// plugs to connect to slots after install
+	Connections []SnapConnection
+	// ports to wait for after install
+	Ports []string
+	// keep the snap installed after the suite, in addition to env.Teardown
+	SkipRemoval bool
+}
+
+// Suite runs the lifecycle of a test suite: clean, set up, run the tests,
+// collect the logs and tear down
+type Suite struct {
+	config SuiteConfig
+	start  time.Time
+}
+
+// NewSuite returns a suite for the given snap. It is meant to be driven from
+// TestMain:
+//
+//	func TestMain(m *testing.M) {
+//		os.Exit(utils.NewSuite(utils.SuiteConfig{
+//			Snap:  "matter-all-clusters-app",
+//			Ports: []string{"5540"},
+//		}).Run(m))
+//	}
+func NewSuite(config SuiteConfig) *Suite {
+	return &Suite{config: config}
+}
+
+// Setup removes any existing installation of the snap, installs it from the
+// source set via environment variables, connects its plugs and waits for its
+// ports. When resuming after a reboot, see env.RebootResume, it keeps the
+// existing installation.
+func (s *Suite) Setup() error {
+	if env.RebootResume() {
+		// keep the installation from before the reboot, to verify it
+		log.Println("[RESUME]")
+		s.start = time.Now()
+		return nil
+	}
+
+	log.Println("[CLEAN]")
+	SnapRemove(nil, s.config.Snap)
+
+	log.Println("[SETUP]")
+	s.start = time.Now()
+
+	if err := SnapInstallFromEnv(nil, s.config.Snap, s.config.InstallOptions...); err != nil {
+		return fmt.Errorf("install: %s", err)
+	}
+
+	for _, c := range s.config.Connections {
+		if err := SnapConnect(nil, c.Plug, c.Slot); err != nil {
+			return fmt.Errorf("connect %s to %s: %s", c.Plug, c.Slot, err)
+		}
+	}
+
+	if len(s.config.Ports) != 0 {
+		if err := WaitServiceOnline(nil, 60, s.config.Ports...); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Teardown writes the snap's logs since setup and removes the snap, unless
+// disabled by the config or env.Teardown, or a reboot checkpoint is pending
+func (s *Suite) Teardown() {
+	log.Println("[TEARDOWN]")
+	SnapDumpLogs(nil, s.start, s.config.Snap)
+
+	remove := env.Teardown() && !s.config.SkipRemoval && !rebootCheckpointPending()
+	log.Println("Removing installed snap:", remove)
+	if remove {
+		SnapRemove(nil, s.config.Snap)
+	}
+}
+
+// Run sets up the suite, runs the tests with RunTests and tears down.
+// It returns the exit code for os.Exit.
+func (s *Suite) Run(m interface{ Run() int }) int {
+	if err := s.Setup(); err != nil {
+		log.Printf("Failed to set up %s: %s", s.config.Snap, err)
+		s.Teardown()
+		return 1
+	}
+	defer s.Teardown()
+
+	return RunTests(m)
+}
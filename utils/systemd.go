@@ -0,0 +1,122 @@
+package utils
+
+import (
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// SnapServiceUnit returns the systemd unit name of a snap's service, e.g.
+// snap.matter-all-clusters-app.all-clusters-app.service
+func SnapServiceUnit(snap, service string) string {
+	return fmt.Sprintf("snap.%s.%s.service", snap, service)
+}
+
+// SnapServiceProperty returns a property of a snap service's systemd unit,
+// e.g. NoNewPrivileges
+func SnapServiceProperty(t *testing.T, snap, service, property string) string {
+	out, _, _ := Exec(t, fmt.Sprintf(
+		"systemctl show %s --property=%s --value",
+		SnapServiceUnit(snap, service),
+		property,
+	))
+	return strings.TrimSpace(out)
+}
+
+// RequireSandboxDirective checks a sandboxing directive of a snap service's
+// systemd unit, e.g. ProtectSystem=full
+func RequireSandboxDirective(t *testing.T, snap, service, property, expected string) {
+	value := SnapServiceProperty(t, snap, service, property)
+	if value != expected {
+		t.Fatalf("Service %s has %s=%s instead of %s",
+			SnapServiceUnit(snap, service), property, value, expected)
+	}
+}
+
+// SnapServiceAfter returns the units which a snap service's systemd unit is
+// ordered after, i.e. its After= dependencies
+func SnapServiceAfter(t *testing.T, snap, service string) []string {
+	return strings.Fields(SnapServiceProperty(t, snap, service, "After"))
+}
+
+// dependencyUnit returns the systemd unit of a dependency given as a unit,
+// e.g. network-online.target, or as a snap service, e.g.
+// openthread-border-router.otbr-agent
+func dependencyUnit(dependency string) string {
+	if strings.Contains(dependency, ".") && !strings.HasSuffix(dependency, ".service") &&
+		!strings.HasSuffix(dependency, ".target") && !strings.HasSuffix(dependency, ".socket") {
+		snap, service, _ := strings.Cut(dependency, ".")
+		return SnapServiceUnit(snap, service)
+	}
+	return dependency
+}
+
+// RequireServiceAfter checks that a snap service starts after a dependency,
+// given as a unit or snap service, e.g. openthread-border-router.otbr-agent
+// for an app which needs the border router to be up
+func RequireServiceAfter(t *testing.T, snap, service, dependsOn string) {
+	unit := SnapServiceUnit(snap, service)
+	dependency := dependencyUnit(dependsOn)
+
+	after := SnapServiceAfter(t, snap, service)
+	if !slices.Contains(after, dependency) {
+		ExecVerbose(t, fmt.Sprintf("systemctl list-dependencies --after --no-pager %s || true", unit))
+		t.Fatalf("Service %s is not ordered after %s, After=%s", unit, dependency, strings.Join(after, " "))
+	}
+	t.Logf("Service %s is ordered after %s", unit, dependency)
+}
+
+// SnapSyslogIdentifiers returns the journald SYSLOG_IDENTIFIER values of the
+// recent journal entries written by the processes of a snap service
+func SnapSyslogIdentifiers(t *testing.T, snap, service string) []string {
+	out, _, _ := Exec(t, fmt.Sprintf(
+		"sudo journalctl _SYSTEMD_UNIT=%s --lines=100 --no-pager --output=json --output-fields=SYSLOG_IDENTIFIER",
+		SnapServiceUnit(snap, service),
+	))
+	return parseSyslogIdentifiers(out)
+}
+
+func parseSyslogIdentifiers(out string) (identifiers []string) {
+	for _, line := range strings.Split(out, "\n") {
+		var entry struct {
+			Identifier string `json:"SYSLOG_IDENTIFIER"`
+		}
+		if json.Unmarshal([]byte(line), &entry) != nil || entry.Identifier == "" {
+			continue
+		}
+		if !slices.Contains(identifiers, entry.Identifier) {
+			identifiers = append(identifiers, entry.Identifier)
+		}
+	}
+	return identifiers
+}
+
+// RequireSyslogIdentifier checks that a snap service writes to the journal
+// with the expected identifier, e.g. matter-all-clusters-app.all-clusters-app.
+// The service must have logged already. SnapLogs and the helpers built on it
+// only capture lines containing the snap name, so an unexpected identifier
+// causes log waits to time out.
+func RequireSyslogIdentifier(t *testing.T, snap, service, expected string) {
+	unit := SnapServiceUnit(snap, service)
+	configured := SnapServiceProperty(t, snap, service, "SyslogIdentifier")
+
+	identifiers := SnapSyslogIdentifiers(t, snap, service)
+	if len(identifiers) == 0 {
+		t.Fatalf("Found no journal entries of %s, is the service running?", unit)
+	}
+
+	for _, id := range identifiers {
+		if id != expected {
+			t.Fatalf("Service %s logs with identifier '%s' instead of '%s' (SyslogIdentifier=%s). "+
+				"Check the app's syslog or journal API usage, or the identifier expected by the test.",
+				unit, id, expected, configured)
+		}
+	}
+
+	if !strings.Contains(expected, snap) {
+		t.Logf("Warning: identifier '%s' of %s doesn't contain the snap name, SnapLogs won't capture it",
+			expected, unit)
+	}
+}
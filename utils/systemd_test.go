@@ -0,0 +1,24 @@
+package utils
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseSyslogIdentifiers(t *testing.T) {
+	identifiers := parseSyslogIdentifiers(`{"SYSLOG_IDENTIFIER":"matter-all-clusters-app.all-clusters-app","__CURSOR":"s=1"}
+{"SYSLOG_IDENTIFIER":"chip-all-clusters-app","__CURSOR":"s=2"}
+{"SYSLOG_IDENTIFIER":"matter-all-clusters-app.all-clusters-app","__CURSOR":"s=3"}
+{"__CURSOR":"s=4"}
+`)
+	assert.Equal(t, []string{"matter-all-clusters-app.all-clusters-app", "chip-all-clusters-app"}, identifiers)
+}
+
+func TestDependencyUnit(t *testing.T) {
+	assert.Equal(t, "snap.openthread-border-router.otbr-agent.service",
+		dependencyUnit("openthread-border-router.otbr-agent"))
+	assert.Equal(t, "network-online.target", dependencyUnit("network-online.target"))
+	assert.Equal(t, "snapd.apparmor.service", dependencyUnit("snapd.apparmor.service"))
+	assert.Equal(t, "dbus", dependencyUnit("dbus"))
+}
@@ -0,0 +1,100 @@
+package utils
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+)
+
+// Thread operational dataset TLV types
+const (
+	threadTLVChannel         = 0x00
+	threadTLVPanID           = 0x01
+	threadTLVExtPanID        = 0x02
+	threadTLVNetworkName     = 0x03
+	threadTLVNetworkKey      = 0x05
+	threadTLVMeshLocalPrefix = 0x07
+	threadTLVSecurityPolicy  = 0x0c
+	threadTLVActiveTimestamp = 0x0e
+	threadTLVChannelMask     = 0x35
+)
+
+// ThreadDataset holds the components of a Thread operational dataset
+type ThreadDataset struct {
+	NetworkName string // 1 to 16 bytes
+	Channel     int    // 11 to 26
+	PanID       uint16 // anything but 0xffff
+	NetworkKey  string // 16 bytes in hex
+	// Optional, defaults to OpenThread's dead00beef00cafe
+	ExtPanID string // 8 bytes in hex
+	// Optional, defaults to OpenThread's fdde:ad00:beef:0::/64
+	MeshLocalPrefix string // 8 bytes in hex
+}
+
+// Encode validates the components and returns the active operational dataset
+// as TLVs in hex, as expected by chip-tool's "hex:" arguments
+func (d ThreadDataset) Encode() (string, error) {
+	if len(d.NetworkName) < 1 || len(d.NetworkName) > 16 {
+		return "", fmt.Errorf("network name must be 1 to 16 bytes, got %d", len(d.NetworkName))
+	}
+	if d.Channel < 11 || d.Channel > 26 {
+		return "", fmt.Errorf("channel must be between 11 and 26, got %d", d.Channel)
+	}
+	if d.PanID == 0xffff {
+		return "", fmt.Errorf("PAN ID 0xffff is reserved for broadcast")
+	}
+
+	networkKey, err := decodeHexBytes("network key", d.NetworkKey, 16)
+	if err != nil {
+		return "", err
+	}
+
+	if d.ExtPanID == "" {
+		d.ExtPanID = "dead00beef00cafe"
+	}
+	extPanID, err := decodeHexBytes("extended PAN ID", d.ExtPanID, 8)
+	if err != nil {
+		return "", err
+	}
+
+	if d.MeshLocalPrefix == "" {
+		d.MeshLocalPrefix = "fddead00beef0000"
+	}
+	meshLocalPrefix, err := decodeHexBytes("mesh local prefix", d.MeshLocalPrefix, 8)
+	if err != nil {
+		return "", err
+	}
+
+	var tlvs []byte
+	appendTLV := func(tlvType byte, value []byte) {
+		tlvs = append(tlvs, tlvType, byte(len(value)))
+		tlvs = append(tlvs, value...)
+	}
+
+	// 48-bit seconds, 15-bit ticks and the authoritative bit: 1 second
+	appendTLV(threadTLVActiveTimestamp, []byte{0, 0, 0, 0, 0, 1, 0, 0})
+	// channel page 0
+	appendTLV(threadTLVChannel, binary.BigEndian.AppendUint16([]byte{0}, uint16(d.Channel)))
+	// channel page 0, 4-byte mask of channels 11 to 26
+	appendTLV(threadTLVChannelMask, []byte{0, 4, 0x00, 0x1f, 0xff, 0xe0})
+	appendTLV(threadTLVExtPanID, extPanID)
+	appendTLV(threadTLVMeshLocalPrefix, meshLocalPrefix)
+	appendTLV(threadTLVNetworkKey, networkKey)
+	appendTLV(threadTLVNetworkName, []byte(d.NetworkName))
+	appendTLV(threadTLVPanID, binary.BigEndian.AppendUint16(nil, d.PanID))
+	// key rotation of 672 hours and the default security flags
+	appendTLV(threadTLVSecurityPolicy, []byte{0x02, 0xa0, 0xf7, 0xf8})
+
+	return hex.EncodeToString(tlvs), nil
+}
+
+func decodeHexBytes(name, value string, length int) ([]byte, error) {
+	b, err := hex.DecodeString(value)
+	if err != nil {
+		return nil, fmt.Errorf("%s is not valid hex: %s", name, err)
+	}
+	if len(b) != length {
+		return nil, fmt.Errorf("%s must be %d bytes, got %d", name, length, len(b))
+	}
+	return b, nil
+}
@@ -0,0 +1,52 @@
+package utils
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestThreadDataset(t *testing.T) {
+	valid := ThreadDataset{
+		NetworkName: "OpenThread",
+		Channel:     15,
+		PanID:       0x1234,
+		NetworkKey:  "00112233445566778899aabbccddeeff",
+	}
+
+	t.Run("encode", func(t *testing.T) {
+		dataset, err := valid.Encode()
+		require.NoError(t, err)
+		assert.Equal(t,
+			"0e080000000000010000"+
+				"000300000f"+
+				"35060004001fffe0"+
+				"0208dead00beef00cafe"+
+				"0708fddead00beef0000"+
+				"051000112233445566778899aabbccddeeff"+
+				"030a4f70656e546872656164"+
+				"01021234"+
+				"0c0402a0f7f8",
+			dataset)
+	})
+
+	t.Run("invalid components", func(t *testing.T) {
+		for name, modify := range map[string]func(d *ThreadDataset){
+			"empty network name": func(d *ThreadDataset) { d.NetworkName = "" },
+			"long network name":  func(d *ThreadDataset) { d.NetworkName = "OpenThreadNetwork" },
+			"low channel":        func(d *ThreadDataset) { d.Channel = 10 },
+			"high channel":       func(d *ThreadDataset) { d.Channel = 27 },
+			"broadcast PAN ID":   func(d *ThreadDataset) { d.PanID = 0xffff },
+			"short network key":  func(d *ThreadDataset) { d.NetworkKey = "0011" },
+			"non-hex ext PAN ID": func(d *ThreadDataset) { d.ExtPanID = "dead00beef00cafz" },
+		} {
+			t.Run(name, func(t *testing.T) {
+				d := valid
+				modify(&d)
+				_, err := d.Encode()
+				assert.Error(t, err)
+			})
+		}
+	})
+}
@@ -0,0 +1,63 @@
+package utils
+
+import (
+	"bytes"
+	"runtime/pprof"
+	"sync"
+	"testing"
+	"time"
+)
+
+// WithTimeout runs fn as a subtest and fails the test if it doesn't return
+// within the given duration. On timeout, it dumps the goroutines and kills
+// the processes which the helpers started for fn's test or its subtests,
+// e.g. a hanging chip-tool, so that fn fails and returns. It then writes the goroutine dump and the journal of the
+// given snaps to the log directory before failing, giving actionable
+// artifacts for hanging tests instead of Go's test binary timeout.
+//
+// Processes of other tests, e.g. a log follower of the calling test, are left
+// running. So are commands executed without a test, e.g. Exec(nil, ...), and a
+// fn which doesn't return after its processes were killed, e.g. one which
+// sleeps, is left to Go's test binary timeout.
+func WithTimeout(t *testing.T, d time.Duration, fn func(t *testing.T), snaps ...string) {
+	t.Helper()
+
+	start := time.Now()
+	var mutex sync.Mutex
+	var goroutines *bytes.Buffer
+
+	t.Run("within "+d.String(), func(t *testing.T) {
+		timer := time.AfterFunc(d-time.Since(start), func() {
+			dump := new(bytes.Buffer)
+			if err := pprof.Lookup("goroutine").WriteTo(dump, 2); err != nil {
+				dump.WriteString("Error dumping goroutines: " + err.Error())
+			}
+			mutex.Lock()
+			goroutines = dump
+			mutex.Unlock()
+
+			killTestProcesses(t.Name())
+		})
+		defer timer.Stop()
+
+		fn(t)
+	})
+
+	mutex.Lock()
+	defer mutex.Unlock()
+	if goroutines == nil {
+		if t.Failed() {
+			t.FailNow()
+		}
+		return
+	}
+
+	if _, err := WriteLogFile(t, "goroutines", goroutines.String()); err != nil {
+		t.Logf("Error writing goroutine dump: %s", err)
+	}
+	for _, snap := range snaps {
+		SnapDumpLogs(t, start, snap)
+	}
+
+	t.Fatalf("Time out: test did not complete within %s", d)
+}
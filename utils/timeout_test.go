@@ -0,0 +1,60 @@
+package utils
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWithTimeout(t *testing.T) {
+	var completed bool
+	WithTimeout(t, time.Second, func(t *testing.T) {
+		completed = true
+	})
+	assert.True(t, completed)
+}
+
+const followerSurvivedMarker = "Follower of the calling test survived the timeout"
+
+func TestWithTimeoutChild(t *testing.T) {
+	childTest(t)
+	logDirectory = os.Getenv("UTILS_CHILD_LOG_DIRECTORY")
+
+	// a process of the calling test, which must be left running
+	fakeSudo(t, "exec sleep 60")
+	f := FollowSnapLogs(t, "timeout-test-snap")
+	t.Cleanup(func() {
+		if processGroupAlive(f.cmd.Process.Pid) {
+			fmt.Println(followerSurvivedMarker)
+		}
+	})
+
+	WithTimeout(t, 500*time.Millisecond, func(t *testing.T) {
+		// a child of bash, which must be killed too
+		ExecVerbose(t, "sleep 30 && true")
+		t.Log("Returned from the killed command")
+	})
+}
+
+func TestWithTimeoutExpired(t *testing.T) {
+	logs := t.TempDir()
+	t.Setenv("UTILS_CHILD_LOG_DIRECTORY", logs)
+
+	start := time.Now()
+	output, err := runChildTest(t, "TestWithTimeoutChild")
+	require.Error(t, err, "exit status of timed out test")
+	assert.Less(t, time.Since(start), 10*time.Second, "hanging command wasn't killed")
+
+	assert.Contains(t, output, "Time out: test did not complete within 500ms")
+	assert.Contains(t, output, "--- FAIL: TestWithTimeoutChild/within_500ms")
+	assert.NotContains(t, output, "Returned from the killed command", "fn went on after failing")
+	assert.NotContains(t, output, "panic:")
+	assert.Contains(t, output, followerSurvivedMarker)
+
+	assert.FileExists(t, filepath.Join(logs, "TestWithTimeoutChild-goroutines.log"))
+}
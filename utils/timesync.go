@@ -0,0 +1,122 @@
+package utils
+
+import (
+	"fmt"
+	"strconv"
+	"testing"
+	"time"
+)
+
+// start of the Matter epoch, which UTC times are relative to
+var matterEpoch = time.Date(2000, time.January, 1, 0, 0, 0, 0, time.UTC)
+
+// TimeSync holds the attributes of the Time Synchronization cluster
+type TimeSync struct {
+	UTCTime     time.Time // zero if the device has no time
+	Granularity string    // e.g. 3 for milliseconds
+	TimeZones   []TimeZone
+}
+
+// TimeZone is an entry of the TimeZone attribute
+type TimeZone struct {
+	Offset  time.Duration // offset from UTC
+	ValidAt time.Time
+	Name    string
+}
+
+// ChipToolReadTimeSync reads the Time Synchronization cluster of a device
+func ChipToolReadTimeSync(t *testing.T, nodeID string) TimeSync {
+	var timeSync TimeSync
+	var err error
+
+	value := ChipToolReadAttribute(t, nodeID, "timesynchronization", "utctime", "0")
+	if timeSync.UTCTime, err = parseMatterTime(value); err != nil {
+		t.Fatalf("Invalid UTC time '%s': %s", value, err)
+	}
+	timeSync.Granularity = ChipToolReadAttribute(t, nodeID, "timesynchronization", "granularity", "0")
+
+	stdout, _, _ := ChipTool(t, readAttributeCommand(nodeID, "timesynchronization", "time-zone", "0"))
+	if timeSync.TimeZones, err = parseTimeZones(stdout); err != nil {
+		t.Fatalf("Invalid time zone: %s", err)
+	}
+
+	t.Logf("Time synchronization of node %s: %+v", nodeID, timeSync)
+	return timeSync
+}
+
+// parseMatterTime parses microseconds since the Matter epoch, or null
+func parseMatterTime(value string) (time.Time, error) {
+	if value == "null" {
+		return time.Time{}, nil
+	}
+	us, err := strconv.ParseUint(value, 10, 64)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return matterEpoch.Add(time.Duration(us) * time.Microsecond), nil
+}
+
+// matterTime returns the microseconds since the Matter epoch
+func matterTime(t time.Time) uint64 {
+	return uint64(t.Sub(matterEpoch) / time.Microsecond)
+}
+
+// parseTimeZones parses the entries of the TimeZone attribute:
+//
+//	[TOO]   TimeZone: 1 entries
+//	[TOO]     [1]: {
+//	[TOO]       Offset: 3600
+//	[TOO]       ValidAt: 0
+//	[TOO]       Name: Europe/Berlin
+//	[TOO]      }
+func parseTimeZones(output string) (zones []TimeZone, err error) {
+	for _, entry := range parseListStructs(output) {
+		offset, err := strconv.Atoi(entry["Offset"])
+		if err != nil {
+			return nil, fmt.Errorf("offset: %s", err)
+		}
+		validAt, err := parseMatterTime(entry["ValidAt"])
+		if err != nil {
+			return nil, fmt.Errorf("valid at: %s", err)
+		}
+		zones = append(zones, TimeZone{
+			Offset:  time.Duration(offset) * time.Second,
+			ValidAt: validAt,
+			Name:    entry["Name"],
+		})
+	}
+	return zones, nil
+}
+
+// ChipToolSetUTCTime sets the UTC time of a device, with millisecond granularity
+func ChipToolSetUTCTime(t *testing.T, nodeID string, utc time.Time) error {
+	const millisecondsGranularity = 3
+
+	_, stderr, err := ChipTool(t, fmt.Sprintf(
+		"timesynchronization set-utctime %d %d %s 0",
+		matterTime(utc),
+		millisecondsGranularity,
+		nodeID,
+	))
+	if err != nil {
+		return fmt.Errorf("%s: %s", err, stderr)
+	}
+	return nil
+}
+
+// RequireUTCTimeSet sets the UTC time of a device to the current time and
+// checks that the device reports it back, within the tolerance
+func RequireUTCTimeSet(t *testing.T, nodeID string, tolerance time.Duration) {
+	if err := ChipToolSetUTCTime(t, nodeID, time.Now()); err != nil {
+		t.Fatalf("Error setting UTC time: %s", err)
+	}
+
+	timeSync := ChipToolReadTimeSync(t, nodeID)
+	if timeSync.UTCTime.IsZero() {
+		t.Fatalf("Node %s has no UTC time after setting it", nodeID)
+	}
+	if diff := time.Since(timeSync.UTCTime).Abs(); diff > tolerance {
+		t.Fatalf("Node %s has UTC time %s, off by %s (tolerance %s)",
+			nodeID, timeSync.UTCTime, diff, tolerance)
+	}
+}
@@ -0,0 +1,68 @@
+package utils
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// ChipToolTrace returns the chip-tool options which write a decoded trace of
+// the exchanged messages, e.g. for ChipToolPairOnNetwork, and the path of the
+// trace file. The trace is written to chip-tool's storage directory because
+// the confined chip-tool can't write to the log directory. It is moved to the
+// log directory on cleanup.
+func ChipToolTrace(t *testing.T, label string) (options, tracePath string) {
+	name := strings.TrimSuffix(filepath.Base(logFileName(t, label)), ".log") + ".trace"
+	tracePath = filepath.Join(ChipToolStorageDir, name)
+
+	t.Cleanup(func() {
+		trace, _, err := Exec(nil, "sudo cat "+tracePath)
+		if err != nil {
+			t.Logf("No chip-tool trace at %s", tracePath)
+			return
+		}
+		path, err := WriteLogFile(t, label+"-trace", trace)
+		if err != nil {
+			t.Logf("Error writing chip-tool trace: %s", err)
+		} else {
+			t.Logf("Wrote chip-tool trace to %s", path)
+		}
+		Exec(nil, "sudo rm -f "+tracePath)
+	})
+
+	return fmt.Sprintf("--trace_decode 1 --trace_file %s", tracePath), tracePath
+}
+
+// traceMessages returns the lines of a decoded trace which mention the
+// message type, e.g. "PASE" or "PBKDFParamRequest", ignoring case
+func traceMessages(trace, messageType string) (lines []string) {
+	messageType = strings.ToLower(messageType)
+	for _, line := range strings.Split(trace, "\n") {
+		if strings.Contains(strings.ToLower(line), messageType) {
+			lines = append(lines, strings.TrimSpace(line))
+		}
+	}
+	return lines
+}
+
+// ChipToolTraceMessages returns the lines of a trace written with the options
+// of ChipToolTrace which mention the message type
+func ChipToolTraceMessages(t *testing.T, tracePath, messageType string) []string {
+	trace, stderr, err := Exec(nil, "sudo cat "+tracePath)
+	if err != nil {
+		t.Fatalf("Error reading chip-tool trace: %s: %s", err, stderr)
+	}
+	return traceMessages(trace, messageType)
+}
+
+// RequireTraceMessage checks that a trace written with the options of
+// ChipToolTrace contains messages of the type, e.g. "PASE" to verify that
+// commissioning got as far as establishing a PASE session
+func RequireTraceMessage(t *testing.T, tracePath, messageType string) {
+	messages := ChipToolTraceMessages(t, tracePath, messageType)
+	if len(messages) == 0 {
+		t.Fatalf("chip-tool trace %s has no %s messages", tracePath, messageType)
+	}
+	t.Logf("chip-tool trace has %d lines of %s messages, first: %s", len(messages), messageType, messages[0])
+}
@@ -0,0 +1,44 @@
+package utils
+
+import (
+	"bytes"
+	"fmt"
+	"log"
+	"os"
+	"runtime/pprof"
+	"time"
+)
+
+// startSuiteWatchdog aborts the test binary if the suite runs longer than the
+// budget, after writing the running processes, the journal of the run and a
+// goroutine dump to the log directory. Unlike Go's -timeout, it leaves
+// artifacts showing what the suite was stuck on and kills tracked background
+// processes.
+func startSuiteWatchdog(budget time.Duration) *time.Timer {
+	start := time.Now()
+	return time.AfterFunc(budget, func() {
+		log.Printf("Time out: test suite exceeded the maximum duration of %s", budget)
+
+		processes, _, _ := Exec(nil, "ps -eo pid,ppid,etime,cmd --forest")
+		journal, _, _ := Exec(nil, fmt.Sprintf(
+			"sudo journalctl --no-pager --since \"%s\" | tail -n 1000",
+			start.Format("2006-01-02 15:04:05"),
+		))
+		var goroutines bytes.Buffer
+		pprof.Lookup("goroutine").WriteTo(&goroutines, 2)
+
+		for label, content := range map[string]string{
+			"suite-timeout-processes":  processes,
+			"suite-timeout-journal":    journal,
+			"suite-timeout-goroutines": goroutines.String(),
+		} {
+			if _, err := WriteLogFile(nil, label, content); err != nil {
+				log.Printf("Error writing %s: %s", label, err)
+			}
+		}
+
+		KillTrackedProcesses()
+		log.Printf("Aborted test suite after %s, see the suite-timeout logs", budget)
+		os.Exit(1)
+	})
+}
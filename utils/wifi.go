@@ -0,0 +1,69 @@
+package utils
+
+import (
+	"encoding/hex"
+	"strings"
+	"testing"
+)
+
+// CommissionedNetwork is an entry of the Network Commissioning cluster's
+// Networks attribute
+type CommissionedNetwork struct {
+	NetworkID string // the SSID for WiFi networks
+	Connected bool
+}
+
+// ChipToolReadNetworks returns the networks a device is provisioned with
+func ChipToolReadNetworks(t *testing.T, nodeID string) []CommissionedNetwork {
+	stdout, _, _ := ChipTool(t, readAttributeCommand(nodeID, "networkcommissioning", "networks", "0"))
+	return parseNetworks(stdout)
+}
+
+// parseNetworks parses the entries of the Networks attribute:
+//
+//	[TOO]   Networks: 1 entries
+//	[TOO]     [1]: {
+//	[TOO]       NetworkID: 4D7953534944
+//	[TOO]       Connected: TRUE
+//	[TOO]      }
+func parseNetworks(output string) (networks []CommissionedNetwork) {
+	for _, line := range strings.Split(output, "\n") {
+		match := chipToolLineExp.FindStringSubmatch(line)
+		if match == nil {
+			continue
+		}
+		key, value, _ := strings.Cut(strings.TrimSpace(match[1]), ":")
+		value = strings.TrimSpace(value)
+
+		switch key {
+		case "NetworkID":
+			id := strings.TrimPrefix(value, "hex:")
+			// the network ID of WiFi networks is the SSID in hex
+			if b, err := hex.DecodeString(id); err == nil {
+				id = string(b)
+			}
+			networks = append(networks, CommissionedNetwork{NetworkID: id})
+		case "Connected":
+			if len(networks) > 0 {
+				networks[len(networks)-1].Connected = value == "TRUE"
+			}
+		}
+	}
+	return networks
+}
+
+// RequireWiFiConnected checks that a commissioned device reports being
+// connected to the WiFi network with the given SSID
+func RequireWiFiConnected(t *testing.T, nodeID, ssid string) {
+	networks := ChipToolReadNetworks(t, nodeID)
+	for _, n := range networks {
+		if n.NetworkID == ssid {
+			if !n.Connected {
+				t.Fatalf("Device %s is provisioned with WiFi network '%s' but not connected", nodeID, ssid)
+			}
+			t.Logf("Device %s is connected to WiFi network '%s'", nodeID, ssid)
+			return
+		}
+	}
+	t.Fatalf("Device %s is not provisioned with WiFi network '%s': %v", nodeID, ssid, networks)
+}
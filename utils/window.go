@@ -0,0 +1,78 @@
+package utils
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Values of the WindowStatus attribute of the Administrator Commissioning
+// cluster
+const (
+	WindowStatusNotOpen         = "0"
+	WindowStatusEnhancedOpen    = "1"
+	WindowStatusBasicWindowOpen = "2"
+)
+
+// error of the cluster-specific WindowNotOpen status, 0x04, of the
+// Administrator Commissioning cluster
+const windowNotOpenError = "IM Error 0x00000604"
+
+// ChipToolOpenCommissioningWindow opens an enhanced commissioning window on a
+// commissioned device for the given number of seconds, using the default
+// discriminator
+func ChipToolOpenCommissioningWindow(t *testing.T, nodeID string, seconds int) error {
+	_, stderr, err := ChipTool(t, fmt.Sprintf(
+		"pairing open-commissioning-window %s 1 %d 1000 %s",
+		nodeID,
+		seconds,
+		DefaultDiscriminator,
+	))
+	if err != nil {
+		return fmt.Errorf("%s: %s", err, stderr)
+	}
+	return nil
+}
+
+// ChipToolCloseCommissioningWindow closes the commissioning window of a
+// commissioned device, if open, and checks that its WindowStatus is closed
+func ChipToolCloseCommissioningWindow(t *testing.T, nodeID string) {
+	const timeout = 1 * time.Minute
+
+	// revoking fails with the WindowNotOpen status if the window is closed
+	// already, which is checked instead
+	stdout, stderr, err := ExecVerbose(nil, chipToolTimeoutCommand(timeout, fmt.Sprintf(
+		"administratorcommissioning revoke-commissioning %s 0 --timedInteractionTimeoutMs 10000",
+		nodeID,
+	)))
+	switch {
+	case ExitCode(err) == timeoutExitCode:
+		t.Logf("Time out: revoking commissioning of node %s did not complete within %s", nodeID, timeout)
+	case err != nil && !strings.Contains(stdout+stderr, windowNotOpenError):
+		t.Logf("Error revoking commissioning of node %s: %s: %s", nodeID, err, stderr)
+	}
+
+	status := ChipToolReadAttribute(t, nodeID, "administratorcommissioning", "window-status", "0")
+	if status != WindowStatusNotOpen {
+		t.Fatalf("Commissioning window of node %s is still open, WindowStatus: %s", nodeID, status)
+	}
+}
+
+// RequireCommissioningRefused closes the commissioning window of a
+// commissioned device and checks that commissioning it again as another
+// node fails within a timeout with the CHIP error, e.g. 0x00000032 for
+// timing out on discovering a commissionable node. This validates that the
+// device doesn't stay commissionable.
+func RequireCommissioningRefused(t *testing.T, nodeID, newNodeID, wantCode string) {
+	ChipToolCloseCommissioningWindow(t, nodeID)
+
+	for _, s := range BrowseMDNS(t, MDNSCommissionable) {
+		if s.TXT["D"] == DefaultDiscriminator {
+			t.Logf("Warning: a device with discriminator %s is still advertised as commissionable: %s",
+				DefaultDiscriminator, s.Name)
+		}
+	}
+
+	ChipToolPairExpectFailure(t, newNodeID, DefaultSetupPINCode, wantCode)
+}